@@ -0,0 +1,379 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/trading-peter/gowebbuild/fsutils"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
+	"github.com/urfave/cli/v2"
+)
+
+//go:embed templates/treemap.html
+var treemapTemplate string
+
+// ownCode groups the inputs outside of node_modules in the package breakdown and the treemap.
+const ownCode = "(own code)"
+
+type analyzedMetafile struct {
+	Path string
+	Meta *gowebbuild.Metafile
+}
+
+// sizeEntry is an input or package with the number of bytes it contributes to an output.
+type sizeEntry struct {
+	Name  string
+	Bytes int
+}
+
+// analyzeAction prints the largest inputs of every output file and how much each npm package contributes,
+// based on the metafiles of the setups (or the metafiles given as arguments).
+func analyzeAction(ctx *cli.Context) error {
+	paths := ctx.Args().Slice()
+
+	if len(paths) == 0 {
+		cfgPath := fsutils.ExpandPath(ctx.String("c"))
+		os.Chdir(filepath.Dir(cfgPath))
+
+		opts, err := gowebbuild.SelectSetups(readCfg(cfgPath), ctx.StringSlice("only"), ctx.StringSlice("skip"))
+		if err != nil {
+			return err
+		}
+
+		for i, o := range opts {
+			path := gowebbuild.MetafilePath(o)
+			if path == "" || !fsutils.IsFile(path) {
+				return fmt.Errorf("no metafile found for setup %s, set metafile or esbuild.metafile in the config or build with --metafile", gowebbuild.SetupName(o, i))
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	metafiles := []analyzedMetafile{}
+	for _, path := range paths {
+		meta, err := gowebbuild.ReadMetafile(path)
+		if err != nil {
+			return err
+		}
+		metafiles = append(metafiles, analyzedMetafile{Path: path, Meta: meta})
+	}
+
+	top := ctx.Int("top")
+	for _, m := range metafiles {
+		fmt.Println(m.Path)
+
+		for _, out := range sortedOutputs(m.Meta) {
+			inputs := outputInputs(m.Meta.Outputs[out])
+			if len(inputs) == 0 {
+				continue
+			}
+
+			fmt.Printf("\n  %s  %s\n", out, gowebbuild.FormatSize(int64(m.Meta.Outputs[out].Bytes)))
+			printSizes(inputs[:min(top, len(inputs))], m.Meta.Outputs[out].Bytes)
+		}
+
+		total := 0
+		for _, out := range m.Meta.Outputs {
+			total += out.Bytes
+		}
+
+		fmt.Println("\n  Packages")
+		printSizes(packageSizes(m.Meta), total)
+		fmt.Println()
+	}
+
+	if out := ctx.String("html"); out != "" {
+		if err := writeTreemap(out, metafiles); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote treemap to %s\n", out)
+	}
+
+	return nil
+}
+
+func printSizes(entries []sizeEntry, total int) {
+	for _, e := range entries {
+		share := 0.0
+		if total > 0 {
+			share = float64(e.Bytes) * 100 / float64(total)
+		}
+		fmt.Printf("    %10s  %5.1f%%  %s\n", gowebbuild.FormatSize(int64(e.Bytes)), share, e.Name)
+	}
+}
+
+// sortedOutputs returns the output files of the metafile, the largest first.
+func sortedOutputs(meta *gowebbuild.Metafile) []string {
+	outputs := []string{}
+	for out := range meta.Outputs {
+		outputs = append(outputs, out)
+	}
+
+	sort.Slice(outputs, func(i, j int) bool {
+		a, b := meta.Outputs[outputs[i]].Bytes, meta.Outputs[outputs[j]].Bytes
+		if a != b {
+			return a > b
+		}
+		return outputs[i] < outputs[j]
+	})
+
+	return outputs
+}
+
+// outputInputs returns the inputs of an output file, the largest first.
+func outputInputs(out gowebbuild.MetafileOutput) []sizeEntry {
+	entries := []sizeEntry{}
+	for name, in := range out.Inputs {
+		entries = append(entries, sizeEntry{Name: name, Bytes: in.BytesInOutput})
+	}
+
+	sortSizes(entries)
+	return entries
+}
+
+// packageSizes sums up the bytes each npm package contributes to all output files.
+func packageSizes(meta *gowebbuild.Metafile) []sizeEntry {
+	sizes := map[string]int{}
+	for _, out := range meta.Outputs {
+		for name, in := range out.Inputs {
+			sizes[packageOf(name)] += in.BytesInOutput
+		}
+	}
+
+	entries := []sizeEntry{}
+	for name, bytes := range sizes {
+		entries = append(entries, sizeEntry{Name: name, Bytes: bytes})
+	}
+
+	sortSizes(entries)
+	return entries
+}
+
+func packageOf(input string) string {
+	if pkg := gowebbuild.PackageName(input); pkg != "" {
+		return pkg
+	}
+
+	return ownCode
+}
+
+func sortSizes(entries []sizeEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Bytes != entries[j].Bytes {
+			return entries[i].Bytes > entries[j].Bytes
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+// treemapNode is a rectangle of the treemap. The position is in percent of the parent rectangle.
+type treemapNode struct {
+	Name                     string
+	Title                    string
+	Color                    template.CSS
+	Left, Top, Width, Height float64
+	Children                 []*treemapNode
+}
+
+type treemapChart struct {
+	Name  string
+	Size  string
+	Nodes []*treemapNode
+}
+
+// The treemaps are laid out for this size, the page scales them to the width of the window.
+const treemapWidth, treemapHeight = 1600.0, 900.0
+
+// writeTreemap writes a self-contained HTML page with a treemap of every output file: its inputs grouped by npm package.
+func writeTreemap(path string, metafiles []analyzedMetafile) error {
+	charts := []treemapChart{}
+
+	for _, m := range metafiles {
+		for _, out := range sortedOutputs(m.Meta) {
+			output := m.Meta.Outputs[out]
+			if len(output.Inputs) == 0 {
+				continue
+			}
+
+			groups := map[string][]sizeEntry{}
+			for name, in := range output.Inputs {
+				pkg := packageOf(name)
+				groups[pkg] = append(groups[pkg], sizeEntry{Name: name, Bytes: in.BytesInOutput})
+			}
+
+			packages := []sizeEntry{}
+			for pkg, entries := range groups {
+				sortSizes(entries)
+				sum := 0
+				for _, e := range entries {
+					sum += e.Bytes
+				}
+				packages = append(packages, sizeEntry{Name: pkg, Bytes: sum})
+			}
+			sortSizes(packages)
+
+			nodes := []*treemapNode{}
+			rects := squarify(packages, treemapWidth, treemapHeight)
+			for i, pkg := range packages {
+				r := rects[i]
+				// Empty packages (like a file with only imports) have no area, the positions of their files would divide by zero.
+				if r.w <= 0 || r.h <= 0 {
+					continue
+				}
+
+				node := &treemapNode{
+					Name:   pkg.Name,
+					Title:  fmt.Sprintf("%s: %s", pkg.Name, gowebbuild.FormatSize(int64(pkg.Bytes))),
+					Color:  template.CSS(fmt.Sprintf("hsl(%d, 55%%, 75%%)", (i*47)%360)),
+					Left:   r.x / treemapWidth * 100,
+					Top:    r.y / treemapHeight * 100,
+					Width:  r.w / treemapWidth * 100,
+					Height: r.h / treemapHeight * 100,
+				}
+
+				files := groups[pkg.Name]
+				for j, fr := range squarify(files, r.w, r.h) {
+					if fr.w <= 0 || fr.h <= 0 {
+						continue
+					}
+
+					node.Children = append(node.Children, &treemapNode{
+						Name:   filepath.Base(files[j].Name),
+						Title:  fmt.Sprintf("%s: %s", files[j].Name, gowebbuild.FormatSize(int64(files[j].Bytes))),
+						Left:   fr.x / r.w * 100,
+						Top:    fr.y / r.h * 100,
+						Width:  fr.w / r.w * 100,
+						Height: fr.h / r.h * 100,
+					})
+				}
+
+				nodes = append(nodes, node)
+			}
+
+			charts = append(charts, treemapChart{Name: out, Size: gowebbuild.FormatSize(int64(output.Bytes)), Nodes: nodes})
+		}
+	}
+
+	tpl, err := template.New("treemap").Parse(treemapTemplate)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return tpl.Execute(f, map[string]any{
+		"Title":  strings.Join(metafilePaths(metafiles), ", "),
+		"Charts": charts,
+	})
+}
+
+func metafilePaths(metafiles []analyzedMetafile) []string {
+	paths := []string{}
+	for _, m := range metafiles {
+		paths = append(paths, m.Path)
+	}
+	return paths
+}
+
+type rect struct {
+	x, y, w, h float64
+}
+
+// squarify lays out entries (sorted by size, the largest first) in a w by h rectangle, with rectangles as square as possible.
+// See "Squarified Treemaps" by Bruls, Huizing and van Wijk.
+func squarify(entries []sizeEntry, w, h float64) []rect {
+	total := 0
+	for _, e := range entries {
+		total += e.Bytes
+	}
+
+	rects := make([]rect, len(entries))
+	if total == 0 {
+		return rects
+	}
+
+	// Areas of the entries, scaled to the rectangle.
+	areas := make([]float64, len(entries))
+	for i, e := range entries {
+		areas[i] = float64(e.Bytes) / float64(total) * w * h
+	}
+
+	worst := func(row []float64, side float64) float64 {
+		sum, lo, hi := 0.0, row[0], row[0]
+		for _, a := range row {
+			sum += a
+			lo = min(lo, a)
+			hi = max(hi, a)
+		}
+		// Empty entries have no aspect ratio, they end up in a row of their own without area.
+		if lo == 0 {
+			return math.Inf(1)
+		}
+		return max(side*side*hi/(sum*sum), sum*sum/(side*side*lo))
+	}
+
+	x, y := 0.0, 0.0
+	start := 0
+
+	for start < len(areas) {
+		side := min(w, h)
+		end := start + 1
+		for end < len(areas) && worst(areas[start:end+1], side) <= worst(areas[start:end], side) {
+			end++
+		}
+
+		sum := 0.0
+		for _, a := range areas[start:end] {
+			sum += a
+		}
+
+		// The row fills the shorter side of the remaining rectangle.
+		if w >= h {
+			rowWidth := 0.0
+			if h > 0 {
+				rowWidth = sum / h
+			}
+			offset := y
+			for i := start; i < end; i++ {
+				height := 0.0
+				if rowWidth > 0 {
+					height = areas[i] / rowWidth
+				}
+				rects[i] = rect{x: x, y: offset, w: rowWidth, h: height}
+				offset += height
+			}
+			x += rowWidth
+			w -= rowWidth
+		} else {
+			rowHeight := 0.0
+			if w > 0 {
+				rowHeight = sum / w
+			}
+			offset := x
+			for i := start; i < end; i++ {
+				width := 0.0
+				if rowHeight > 0 {
+					width = areas[i] / rowHeight
+				}
+				rects[i] = rect{x: offset, y: y, w: width, h: rowHeight}
+				offset += width
+			}
+			y += rowHeight
+			h -= rowHeight
+		}
+
+		start = end
+	}
+
+	return rects
+}
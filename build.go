@@ -2,61 +2,159 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"time"
 
-	"github.com/evanw/esbuild/pkg/api"
 	"github.com/trading-peter/gowebbuild/fsutils"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
 	"github.com/urfave/cli/v2"
 )
 
 func buildAction(ctx *cli.Context) error {
-	cfgPath := fsutils.ResolvePath(ctx.String("c"))
+	format := ctx.String("report")
+	if format != "" && format != "json" {
+		return fmt.Errorf("unknown report format %q, expected json", format)
+	}
 
-	os.Chdir(filepath.Dir(cfgPath))
-	opts := readCfg(cfgPath)
+	// The report goes to stdout unless --report-file is set, so everything else is printed to stderr then.
+	out := io.Writer(os.Stdout)
+	if format != "" && ctx.String("report-file") == "" {
+		out = os.Stderr
+	}
+
+	report := newBuildReport(filepath.Dir(fsutils.ExpandPath(ctx.String("c"))))
+	results, err := runBuild(ctx, out, report)
+
+	if err := report.saveSizes(); err != nil {
+		fmt.Fprintf(out, "Failed to save the output sizes: %v\n", err)
+	}
 
-	for _, o := range opts {
-		if ctx.Bool("p") {
-			download(o)
+	if format != "" {
+		if err := report.writeJSON(ctx.String("report-file"), results, err); err != nil {
+			return err
 		}
-		purge(o)
-		cp(o)
+	}
+
+	if err != nil && out == os.Stderr {
+		return cli.Exit(err, 1)
+	}
+
+	return err
+}
+
+// runBuild builds the selected setups and prints a summary to out. The results are nil if no setup ran.
+func runBuild(ctx *cli.Context, out io.Writer, report *buildReport) ([]gowebbuild.Result, error) {
+	cfgPath := fsutils.ExpandPath(ctx.String("c"))
+
+	os.Chdir(filepath.Dir(cfgPath))
+	profile, err := selectedProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		esBuildCfg := cfgToESBuildCfg(o)
+	opts, err := loadCfg(cfgPath, profile, true)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err = gowebbuild.SelectSetups(opts, ctx.StringSlice("only"), ctx.StringSlice("skip"))
+	if err != nil {
+		return nil, err
+	}
+
+	pipelineOptions := []gowebbuild.PipelineOption{}
+	if ctx.Bool("strict") {
+		pipelineOptions = append(pipelineOptions, gowebbuild.WithStrict())
+	}
 
-		if ctx.Bool("p") {
-			esBuildCfg.MinifyIdentifiers = true
-			esBuildCfg.MinifySyntax = true
-			esBuildCfg.MinifyWhitespace = true
-			esBuildCfg.Sourcemap = api.SourceMapNone
+	pipelines := []*gowebbuild.Pipeline{}
+	for i, o := range opts {
+		if ctx.Bool("metafile") && o.Metafile == "" {
+			o.Metafile = gowebbuild.MetafilePath(o)
 		}
 
-		esBuildCfg.Plugins = append(esBuildCfg.Plugins, contentSwapPlugin(o))
+		// Post build commands run after all setups are built.
+		stages := slices.DeleteFunc(gowebbuild.BuildStages(o), func(s gowebbuild.Stage) bool {
+			return s == gowebbuild.StagePostBuild
+		})
 
-		result := api.Build(esBuildCfg)
-		if len(result.Errors) > 0 {
-			os.Exit(1)
+		name := gowebbuild.SetupName(o, i)
+		onEvent := func(ev gowebbuild.Event) {
+			report.onEvent(ev)
+
+			if ev.Message == "" {
+				return
+			}
+
+			if len(opts) > 1 {
+				fmt.Fprintf(out, "[%s] %s\n", name, ev.Message)
+			} else {
+				fmt.Fprintln(out, ev.Message)
+			}
 		}
 
-		replace(o)
+		pipelines = append(pipelines, gowebbuild.New(o, append(pipelineOptions, gowebbuild.WithName(name), gowebbuild.WithStages(stages...), gowebbuild.WithEventHandler(onEvent), gowebbuild.WithCommandOutput(out))...))
+	}
+
+	graphOptions := []gowebbuild.GraphOption{gowebbuild.WithJobs(ctx.Int("jobs"))}
+	if ctx.Bool("keep-going") {
+		graphOptions = append(graphOptions, gowebbuild.WithKeepGoing())
+	}
+
+	results, err := gowebbuild.RunGraph(ctx.Context, pipelines, graphOptions...)
+	if results == nil {
+		return nil, err
+	}
+
+	// Without --keep-going, post build commands only run if all setups were built.
+	if err == nil || ctx.Bool("keep-going") {
+		for i, p := range pipelines {
+			if !p.Options.Production || results[i].Err != nil || results[i].Skipped {
+				continue
+			}
 
-		if ctx.Bool("p") && o.ProductionBuildOptions.CmdPostBuild != "" {
-			defer func() {
-				fmt.Printf("Executing post production build command `%s`\n", o.ProductionBuildOptions.CmdPostBuild)
-				cmd := exec.Command("sh", "-c", o.ProductionBuildOptions.CmdPostBuild)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				err := cmd.Run()
+			start := time.Now()
+			postErr := p.RunStage(ctx.Context, gowebbuild.StagePostBuild)
+			results[i].Duration += time.Since(start)
 
-				if err != nil {
-					fmt.Printf("Failed to execute post production build command `%s`: %+v\n", o.ProductionBuildOptions.CmdPostBuild, err)
-					os.Exit(1)
+			if postErr != nil {
+				results[i].Err = postErr
+				if !ctx.Bool("keep-going") {
+					break
 				}
-			}()
+			}
+		}
+	}
+
+	report.print(out, results)
+
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d setup(s) failed", failed, len(results))
+	}
+
+	return results, ctx.Context.Err()
+}
+
+// selectedProfile returns the profile selected with --profile. -p is an alias for --profile prod.
+func selectedProfile(ctx *cli.Context) (string, error) {
+	profile := ctx.String("profile")
+
+	if ctx.Bool("p") {
+		if profile != "" && profile != "prod" {
+			return "", fmt.Errorf("-p selects the prod profile and can't be combined with --profile %s", profile)
 		}
+		profile = "prod"
 	}
 
-	return nil
+	return profile, nil
 }
@@ -48,24 +48,30 @@ func IsDir(path string) bool {
 	return err == nil && stat.IsDir()
 }
 
-func ResolvePath(path string) string {
+// AbsPath makes a path absolute and expands a leading ~. Environment variables are not expanded, config values
+// are interpolated when the config is loaded already (see ExpandPath for other paths).
+func AbsPath(path string) (string, error) {
 	// We assume that the user doesn't use the involved feature if the path is empty.
 	if path == "" {
-		return ""
+		return "", nil
 	}
 
-	expandedPath := os.ExpandEnv(path)
+	expandedPath := path
 
 	if strings.HasPrefix(expandedPath, "~") {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			fmt.Println(err.Error())
-			os.Exit(1)
+			return "", err
 		}
 		expandedPath = filepath.Join(homeDir, expandedPath[1:])
 	}
 
-	path, err := filepath.Abs(expandedPath)
+	return filepath.Abs(expandedPath)
+}
+
+// ResolvePath is like AbsPath, but prints the error and exits. It's meant for command line arguments.
+func ResolvePath(path string) string {
+	path, err := AbsPath(path)
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
@@ -73,3 +79,9 @@ func ResolvePath(path string) string {
 
 	return path
 }
+
+// ExpandPath expands $VAR and ${VAR} in a path that isn't part of the config, like a command line argument,
+// and resolves it like ResolvePath.
+func ExpandPath(path string) string {
+	return ResolvePath(os.ExpandEnv(path))
+}
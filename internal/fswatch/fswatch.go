@@ -0,0 +1,297 @@
+// Package fswatch watches files and folders for changes. It uses inotify where it's available and falls back to polling
+// for filesystems that don't deliver change events (like network shares) and when inotify runs out of watches.
+package fswatch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend selects how changes are detected.
+type Backend string
+
+const (
+	// BackendNative uses inotify and polls only the paths it can't watch. It's the default.
+	BackendNative Backend = "native"
+	// BackendPoll compares the state of all watched files every Options.Interval.
+	BackendPoll Backend = "poll"
+)
+
+// Op is the kind of a change.
+type Op int
+
+const (
+	Create Op = iota + 1
+	Write
+	Remove
+	Rename
+)
+
+func (o Op) String() string {
+	switch o {
+	case Create:
+		return "create"
+	case Write:
+		return "write"
+	case Remove:
+		return "remove"
+	case Rename:
+		return "rename"
+	}
+
+	return fmt.Sprintf("op(%d)", int(o))
+}
+
+// Event is a change of a watched file or folder.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+// Watcher reports changes of the added files and folders.
+type Watcher interface {
+	// Add watches a file, or a folder with everything below it (including folders created later).
+	Add(path string) error
+	// Remove stops watching a file or folder that was added before.
+	Remove(path string) error
+	// Events delivers the changes. The channel isn't closed by Close.
+	Events() <-chan Event
+	// Errors delivers problems that don't stop the watcher.
+	Errors() <-chan error
+	// Len is the number of watched folders and files.
+	Len() int
+	// Close stops watching.
+	Close() error
+}
+
+// Options configures a Watcher.
+type Options struct {
+	Backend Backend
+	// Interval is the polling interval (default 100ms).
+	Interval time.Duration
+	// Exclude are files and folders that are ignored with everything below them.
+	Exclude []string
+	// Skip is called for the files and folders below added folders. Skipped folders aren't watched at all.
+	Skip func(path string, dir bool) bool
+	// Log receives notices, like which paths are polled because they can't be watched natively.
+	Log func(msg string)
+}
+
+// newNative creates the native backend, tests replace it to check the fallback to polling.
+var newNative = newInotify
+
+// errLimit is returned by the native backend if the system doesn't allow more watches.
+var errLimit = errors.New("inotify watch limit reached (see fs.inotify.max_user_watches)")
+
+// watcher sends the paths to the native backend if possible and to the polling backend otherwise.
+type watcher struct {
+	opts   Options
+	events chan Event
+	errors chan error
+	done   chan struct{}
+
+	mu     sync.Mutex
+	native *inotify
+	poll   *poller
+	closed bool
+}
+
+// New creates a Watcher. Paths are watched natively unless opts.Backend is BackendPoll.
+func New(opts Options) (Watcher, error) {
+	if opts.Backend != "" && opts.Backend != BackendNative && opts.Backend != BackendPoll {
+		return nil, fmt.Errorf("unknown watch backend %q, expected native or poll", opts.Backend)
+	}
+
+	if opts.Interval == 0 {
+		opts.Interval = 100 * time.Millisecond
+	}
+
+	for i, path := range opts.Exclude {
+		if abs, err := filepath.Abs(path); err == nil {
+			opts.Exclude[i] = abs
+		}
+	}
+
+	w := &watcher{
+		opts:   opts,
+		events: make(chan Event, 64),
+		errors: make(chan error, 8),
+		done:   make(chan struct{}),
+	}
+
+	if opts.Backend != BackendPoll {
+		native, err := newNative(w)
+		if err != nil {
+			w.log(fmt.Sprintf("Native file watching isn't available (%v), polling instead", err))
+		} else {
+			w.native = native
+		}
+	}
+
+	return w, nil
+}
+
+func (w *watcher) Add(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errors.New("watcher is closed")
+	}
+
+	if w.native != nil {
+		reason := unsupportedFS(path)
+		if reason == "" {
+			err := w.native.add(path)
+			if !errors.Is(err, errLimit) {
+				return err
+			}
+			reason = err.Error()
+		}
+
+		w.log(fmt.Sprintf("Polling %s: %s", path, reason))
+	}
+
+	if w.poll == nil {
+		w.poll = newPoller(w)
+	}
+
+	return w.poll.add(path)
+}
+
+func (w *watcher) Remove(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.native != nil {
+		w.native.remove(path)
+	}
+
+	if w.poll != nil {
+		return w.poll.remove(path)
+	}
+
+	return nil
+}
+
+func (w *watcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *watcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *watcher) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := 0
+	if w.native != nil {
+		n += w.native.len()
+	}
+	if w.poll != nil {
+		n += w.poll.len()
+	}
+
+	return n
+}
+
+func (w *watcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	close(w.done)
+
+	if w.native != nil {
+		w.native.close()
+	}
+	if w.poll != nil {
+		w.poll.close()
+	}
+
+	return nil
+}
+
+// emit delivers an event unless the watcher is closed. Excluded paths are dropped.
+func (w *watcher) emit(ev Event, dir bool) {
+	if w.excluded(ev.Path, dir) {
+		return
+	}
+
+	select {
+	case w.events <- ev:
+	case <-w.done:
+	}
+}
+
+func (w *watcher) error(err error) {
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	}
+}
+
+func (w *watcher) log(msg string) {
+	if w.opts.Log != nil {
+		w.opts.Log(msg)
+	}
+}
+
+// excluded reports whether a path is below one of the excluded paths or skipped.
+func (w *watcher) excluded(path string, dir bool) bool {
+	for _, ex := range w.opts.Exclude {
+		if path == ex || strings.HasPrefix(path, ex+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return w.opts.Skip != nil && w.opts.Skip(path, dir)
+}
+
+// walkDirs calls fn for root and every folder below it that isn't excluded.
+func (w *watcher) walkDirs(root string, fn func(dir string) error) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Folders can disappear while walking, that's not a reason to stop.
+			if os.IsNotExist(err) && path != root {
+				return nil
+			}
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if path != root && w.excluded(path, true) {
+			return filepath.SkipDir
+		}
+
+		return fn(path)
+	})
+}
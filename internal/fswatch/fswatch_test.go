@@ -0,0 +1,209 @@
+package fswatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var backends = []Backend{BackendNative, BackendPoll}
+
+// testWatcher creates a watcher with the backend that watches a new folder with a.txt in it.
+func testWatcher(t *testing.T, opts Options) (Watcher, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Interval = 10 * time.Millisecond
+	w, err := New(opts)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	if err := w.Add(dir); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	return w, dir
+}
+
+// waitFor waits for an event of path with op and returns the events that came before it.
+func waitFor(t *testing.T, w Watcher, path string, op Op) []Event {
+	t.Helper()
+
+	seen := []Event{}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-w.Events():
+			if ev.Path == path && ev.Op == op {
+				return seen
+			}
+			seen = append(seen, ev)
+		case err := <-w.Errors():
+			t.Fatalf("watcher error = %v", err)
+		case <-timeout:
+			t.Fatalf("no %s event for %s, got %v", op, path, seen)
+		}
+	}
+}
+
+// drain returns the events that arrive within a few polling intervals.
+func drain(w Watcher) []Event {
+	seen := []Event{}
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case ev := <-w.Events():
+			seen = append(seen, ev)
+		case <-timeout:
+			return seen
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		// change modifies the watched folder and returns the path of the expected event.
+		change func(dir string) (string, error)
+		op     Op
+	}{
+		{
+			name: "create",
+			change: func(dir string) (string, error) {
+				path := filepath.Join(dir, "b.txt")
+				return path, os.WriteFile(path, []byte("b"), 0644)
+			},
+			op: Create,
+		},
+		{
+			name: "write",
+			change: func(dir string) (string, error) {
+				path := filepath.Join(dir, "a.txt")
+				return path, os.WriteFile(path, []byte("changed"), 0644)
+			},
+			op: Write,
+		},
+		{
+			name: "remove",
+			change: func(dir string) (string, error) {
+				path := filepath.Join(dir, "a.txt")
+				return path, os.Remove(path)
+			},
+			op: Remove,
+		},
+		{
+			name: "rename",
+			change: func(dir string) (string, error) {
+				path := filepath.Join(dir, "b.txt")
+				return path, os.Rename(filepath.Join(dir, "a.txt"), path)
+			},
+			op: Rename,
+		},
+	}
+
+	for _, backend := range backends {
+		for _, tt := range tests {
+			t.Run(string(backend)+" "+tt.name, func(t *testing.T) {
+				w, dir := testWatcher(t, Options{Backend: backend})
+
+				path, err := tt.change(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				waitFor(t, w, path, tt.op)
+			})
+		}
+	}
+}
+
+func TestNewFolder(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			w, dir := testWatcher(t, Options{Backend: backend})
+			sub := filepath.Join(dir, "sub", "deeper")
+
+			if err := os.MkdirAll(sub, 0755); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, w, sub, Create)
+
+			// Files in folders created after Add are watched as well.
+			path := filepath.Join(sub, "b.txt")
+			if err := os.WriteFile(path, []byte("b"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, w, path, Create)
+		})
+	}
+}
+
+func TestExclude(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			dir := t.TempDir()
+			excluded := filepath.Join(dir, "node_modules")
+			if err := os.MkdirAll(excluded, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			w, err := New(Options{Backend: backend, Interval: 10 * time.Millisecond, Exclude: []string{excluded}})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			t.Cleanup(func() { w.Close() })
+
+			if err := w.Add(dir); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+
+			if err := os.WriteFile(filepath.Join(excluded, "ignored.js"), []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "b.txt")
+			if err := os.WriteFile(path, []byte("b"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			seen := append(waitFor(t, w, path, Create), drain(w)...)
+			for _, ev := range seen {
+				if ev.Path == excluded || strings.HasPrefix(ev.Path, excluded+string(filepath.Separator)) {
+					t.Errorf("got %s event for excluded %s", ev.Op, ev.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestNativeFallback(t *testing.T) {
+	orig := newNative
+	t.Cleanup(func() { newNative = orig })
+	newNative = func(fw *watcher) (*inotify, error) {
+		return nil, errors.New("no inotify")
+	}
+
+	logged := make(chan string, 8)
+	w, dir := testWatcher(t, Options{Log: func(msg string) { logged <- msg }})
+
+	if msg := <-logged; !strings.Contains(msg, "polling instead") {
+		t.Errorf("logged %q, want a notice about polling", msg)
+	}
+	if w.(*watcher).poll == nil {
+		t.Fatal("paths aren't polled")
+	}
+
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, w, path, Write)
+}
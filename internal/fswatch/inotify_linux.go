@@ -0,0 +1,367 @@
+//go:build linux
+
+package fswatch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR | unix.IN_EXCL_UNLINK
+
+// inotify is the native backend. It watches every folder below the added folders and the folders of added files.
+type inotify struct {
+	fw   *watcher
+	fd   int
+	wake [2]int
+	once sync.Once
+
+	mu      sync.Mutex
+	watches map[int]string
+	dirs    map[string]int
+	// roots are the added folders, files are the added files.
+	roots map[string]bool
+	files map[string]bool
+}
+
+func newInotify(fw *watcher) (*inotify, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &inotify{
+		fw:      fw,
+		fd:      fd,
+		watches: map[int]string{},
+		dirs:    map[string]int{},
+		roots:   map[string]bool{},
+		files:   map[string]bool{},
+	}
+
+	// Closing the inotify descriptor doesn't wake up a blocked poll, so close writes to this pipe.
+	if err := unix.Pipe2(n.wake[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	go n.read()
+	return n, nil
+}
+
+func (n *inotify) read() {
+	defer func() {
+		unix.Close(n.fd)
+		unix.Close(n.wake[0])
+		unix.Close(n.wake[1])
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		fds := []unix.PollFd{{Fd: int32(n.fd), Events: unix.POLLIN}, {Fd: int32(n.wake[0]), Events: unix.POLLIN}}
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			n.fw.error(err)
+			return
+		}
+
+		if fds[1].Revents != 0 {
+			return
+		}
+
+		size, err := unix.Read(n.fd, buf)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			n.fw.error(err)
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= size; {
+			wd := int(int32(binary.NativeEndian.Uint32(buf[offset:])))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+
+			start := offset + unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[start:min(start+nameLen, size)]), "\x00")
+			offset = start + nameLen
+
+			n.handle(wd, mask, name)
+		}
+	}
+}
+
+func (n *inotify) handle(wd int, mask uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		// Events got lost, report every root as changed so a rebuild picks up everything.
+		n.fw.error(errors.New("too many changes at once, some were lost"))
+
+		n.mu.Lock()
+		roots := make([]string, 0, len(n.roots))
+		for root := range n.roots {
+			roots = append(roots, root)
+		}
+		n.mu.Unlock()
+
+		for _, root := range roots {
+			n.fw.emit(Event{Path: root, Op: Write}, true)
+		}
+		return
+	}
+
+	n.mu.Lock()
+	dir, ok := n.watches[wd]
+	if mask&unix.IN_IGNORED != 0 && ok {
+		delete(n.watches, wd)
+		delete(n.dirs, dir)
+	}
+	n.mu.Unlock()
+
+	if !ok || mask&unix.IN_IGNORED != 0 {
+		return
+	}
+
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+	isDir := mask&unix.IN_ISDIR != 0
+
+	var op Op
+	switch {
+	case mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0:
+		// The folder itself is reported by its parent, unless it was added on its own.
+		n.mu.Lock()
+		root := n.roots[dir]
+		n.mu.Unlock()
+		if !root {
+			return
+		}
+		op, isDir = Remove, true
+	case mask&unix.IN_CREATE != 0:
+		// New files are reported again when they are written and closed, links and empty files only get this event.
+		op = Create
+	case mask&unix.IN_MOVED_TO != 0:
+		op = Rename
+	case mask&unix.IN_CLOSE_WRITE != 0:
+		op = Write
+	case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		op = Remove
+	default:
+		return
+	}
+
+	if !n.wanted(path) {
+		return
+	}
+
+	if isDir && (op == Create || op == Rename) {
+		if err := n.addTree(path); err != nil && !os.IsNotExist(err) {
+			n.fw.error(fmt.Errorf("failed to watch %s: %w", path, err))
+		}
+	}
+
+	if isDir && op == Remove && path != dir {
+		n.removeTree(path)
+	}
+
+	n.fw.emit(Event{Path: path, Op: op}, isDir)
+
+	if isDir && (op == Create || op == Rename) {
+		n.emitContents(path)
+	}
+}
+
+// emitContents reports everything below a new folder as created. Files and folders that were created before the folder
+// was watched (like with mkdir -p or when copying a folder) don't get events of their own.
+func (n *inotify) emitContents(root string) {
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+
+		if d.IsDir() && n.fw.excluded(path, true) {
+			return filepath.SkipDir
+		}
+
+		n.fw.emit(Event{Path: path, Op: Create}, d.IsDir())
+		return nil
+	})
+}
+
+// wanted reports whether a path is an added file or below an added folder.
+func (n *inotify) wanted(path string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.files[path] {
+		return true
+	}
+
+	for root := range n.roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *inotify) add(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		// Files are watched through their folder, editors often replace files instead of writing them.
+		if err := n.watchDir(filepath.Dir(path)); err != nil {
+			return err
+		}
+
+		n.mu.Lock()
+		n.files[path] = true
+		n.mu.Unlock()
+		return nil
+	}
+
+	n.mu.Lock()
+	n.roots[path] = true
+	n.mu.Unlock()
+
+	if err := n.addTree(path); err != nil {
+		n.remove(path)
+		return err
+	}
+
+	return nil
+}
+
+func (n *inotify) addTree(root string) error {
+	return n.fw.walkDirs(root, n.watchDir)
+}
+
+func (n *inotify) watchDir(dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.dirs[dir]; ok {
+		return nil
+	}
+
+	wd, err := unix.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		if err == unix.ENOSPC {
+			return errLimit
+		}
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+
+	n.dirs[dir] = wd
+	n.watches[wd] = dir
+	return nil
+}
+
+// remove forgets an added path and stops watching the folders that aren't needed anymore.
+func (n *inotify) remove(path string) {
+	n.mu.Lock()
+	delete(n.roots, path)
+	delete(n.files, path)
+	n.mu.Unlock()
+
+	n.removeTree(path)
+	n.removeTree(filepath.Dir(path))
+}
+
+// removeTree stops watching the folders at and below dir that aren't needed by an added path anymore.
+func (n *inotify) removeTree(dir string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for path, wd := range n.dirs {
+		if path != dir && !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			continue
+		}
+
+		if n.needed(path) {
+			continue
+		}
+
+		unix.InotifyRmWatch(n.fd, uint32(wd))
+		delete(n.dirs, path)
+		delete(n.watches, wd)
+	}
+}
+
+// needed reports whether a watched folder is below an added folder or contains an added file. n.mu must be held.
+func (n *inotify) needed(dir string) bool {
+	if _, err := os.Stat(dir); err != nil {
+		return false
+	}
+
+	for root := range n.roots {
+		if dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	for file := range n.files {
+		if filepath.Dir(file) == dir {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *inotify) len() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.dirs)
+}
+
+func (n *inotify) close() {
+	n.once.Do(func() {
+		unix.Write(n.wake[1], []byte{0})
+	})
+}
+
+// Filesystems that don't deliver inotify events for changes made by other machines or the host of a VM.
+var unwatchableFS = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xfe534d42: "smb2",
+	0xff534d42: "cifs",
+	0x01021997: "9p",
+	0x65735546: "fuse",
+	0x786f4256: "vboxsf",
+	0x73757245: "coda",
+	0x5346414f: "afs",
+	0x00c36400: "ceph",
+}
+
+// unsupportedFS returns why a path can't be watched natively, or an empty string if it can.
+func unsupportedFS(path string) string {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return ""
+	}
+
+	// Type is signed and 32 bits wide on some platforms, the magic numbers are unsigned 32 bit values.
+	if name, ok := unwatchableFS[uint32(st.Type)]; ok {
+		return fmt.Sprintf("%s filesystems don't report all changes", name)
+	}
+
+	return ""
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package fswatch
+
+import "errors"
+
+// inotify is only available on Linux, other systems always poll.
+type inotify struct{}
+
+func newInotify(fw *watcher) (*inotify, error) {
+	return nil, errors.New("not supported on this system")
+}
+
+func (n *inotify) add(path string) error { return errors.New("not supported on this system") }
+func (n *inotify) remove(path string)    {}
+func (n *inotify) len() int              { return 0 }
+func (n *inotify) close()                {}
+
+func unsupportedFS(path string) string {
+	return ""
+}
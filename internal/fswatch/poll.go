@@ -0,0 +1,114 @@
+package fswatch
+
+import (
+	"os"
+
+	rwatcher "github.com/radovskyb/watcher"
+)
+
+// poller is the polling backend. It compares the state of the watched files every Options.Interval.
+type poller struct {
+	fw *watcher
+	w  *rwatcher.Watcher
+	// dirs are the folders of every added path.
+	dirs map[string][]string
+}
+
+func newPoller(fw *watcher) *poller {
+	p := &poller{fw: fw, w: rwatcher.New(), dirs: map[string][]string{}}
+	p.w.FilterOps(rwatcher.Write, rwatcher.Rename, rwatcher.Move, rwatcher.Create, rwatcher.Remove)
+
+	for _, ex := range fw.opts.Exclude {
+		p.w.Ignore(ex)
+	}
+
+	go func() {
+		for {
+			select {
+			case event := <-p.w.Event:
+				op := Write
+				switch event.Op {
+				case rwatcher.Create:
+					op = Create
+				case rwatcher.Remove:
+					op = Remove
+				case rwatcher.Rename, rwatcher.Move:
+					op = Rename
+				}
+
+				fw.emit(Event{Path: event.Path, Op: op}, event.IsDir())
+			case err := <-p.w.Error:
+				fw.error(err)
+			case <-p.w.Closed:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		if err := p.w.Start(fw.opts.Interval); err != nil {
+			fw.error(err)
+		}
+	}()
+
+	return p
+}
+
+// add watches a file or a folder. Without a Skip function the polling watcher lists folders recursively by itself,
+// otherwise every folder that isn't skipped is added on its own, so skipped folders (like node_modules) aren't listed at all.
+func (p *poller) add(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		p.dirs[path] = []string{path}
+		return p.w.Add(path)
+	}
+
+	dirs := []string{}
+	if err := p.fw.walkDirs(path, func(dir string) error {
+		dirs = append(dirs, dir)
+		return nil
+	}); err != nil {
+		return err
+	}
+	p.dirs[path] = dirs
+
+	if p.fw.opts.Skip == nil {
+		return p.w.AddRecursive(path)
+	}
+
+	for _, dir := range dirs {
+		if err := p.w.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *poller) remove(path string) error {
+	for _, dir := range p.dirs[path] {
+		if err := p.w.RemoveRecursive(dir); err != nil {
+			return err
+		}
+	}
+
+	delete(p.dirs, path)
+	return nil
+}
+
+func (p *poller) len() int {
+	n := 0
+	for _, dirs := range p.dirs {
+		n += len(dirs)
+	}
+	return n
+}
+
+func (p *poller) close() {
+	p.w.Wait()
+	p.w.Close()
+}
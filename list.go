@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/trading-peter/gowebbuild/fsutils"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
+	"github.com/urfave/cli/v2"
+)
+
+func listAction(ctx *cli.Context) error {
+	cfgPath := fsutils.ExpandPath(ctx.String("c"))
+
+	os.Chdir(filepath.Dir(cfgPath))
+	opts := readCfg(cfgPath)
+	root := filepath.Dir(cfgPath)
+
+	for i, o := range opts {
+		fmt.Println(gowebbuild.SetupName(o, i))
+
+		if len(o.DependsOn) > 0 {
+			fmt.Printf("  after:    %s\n", strings.Join(o.DependsOn, ", "))
+		}
+
+		entries := append([]string{}, o.ESBuild.EntryPoints...)
+		for _, ep := range o.ESBuild.EntryPointsAdvanced {
+			entries = append(entries, ep.In)
+		}
+
+		out := o.ESBuild.Outdir
+		if o.ESBuild.Outfile != "" {
+			out = o.ESBuild.Outfile
+		}
+
+		if len(entries) > 0 {
+			fmt.Printf("  build:    %s -> %s\n", relPaths(root, entries...), relPaths(root, out))
+		}
+
+		for _, c := range o.Copy {
+			fmt.Printf("  copy:     %s -> %s\n", relPaths(root, c.Src), relPaths(root, c.Dest))
+		}
+
+		for _, dl := range o.Download {
+			fmt.Printf("  download: %s -> %s\n", dl.Url, relPaths(root, dl.Dest))
+		}
+
+		for _, r := range o.Replace {
+			fmt.Printf("  replace:  %q with %q in %s\n", r.Search, r.Replace, r.Pattern)
+		}
+
+		if o.Watch.Auto && len(o.Watch.Paths) > 0 {
+			fmt.Printf("  watch:    paths used by the build, %s\n", relPaths(root, o.Watch.Paths...))
+		} else if o.Watch.Auto {
+			fmt.Println("  watch:    paths used by the build")
+		} else if len(o.Watch.Paths) > 0 {
+			fmt.Printf("  watch:    %s\n", relPaths(root, o.Watch.Paths...))
+		}
+
+		if o.Serve.Path != "" {
+			port := o.Serve.Port
+			if port == 0 {
+				port = 8080
+			}
+			fmt.Printf("  serve:    %s on port %d\n", relPaths(root, o.Serve.Path), port)
+		}
+
+		if o.Link.From != "" {
+			fmt.Printf("  link:     %s -> %s\n", relPaths(root, o.Link.From), relPaths(root, o.Link.To))
+		}
+
+		for _, override := range o.NpmProxy.Overrides {
+			fmt.Printf("  npm-proxy: %s from %s\n", override.Namespace, relPaths(root, override.PackageRoot))
+		}
+	}
+
+	return nil
+}
+
+// relPaths formats paths relative to root to keep the output short.
+func relPaths(root string, paths ...string) string {
+	list := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		if rel, err := filepath.Rel(root, p); err == nil && !strings.HasPrefix(rel, "..") {
+			p = rel
+		}
+		list = append(list, p)
+	}
+
+	return strings.Join(list, ", ")
+}
@@ -6,11 +6,13 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"syscall"
-	"time"
 
 	"github.com/jaschaephraim/lrserver"
-	"github.com/radovskyb/watcher"
+	"github.com/trading-peter/gowebbuild/internal/fswatch"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
 	"github.com/urfave/cli/v2"
 )
 
@@ -23,6 +25,21 @@ func main() {
 		Usage: "path to config file config file.",
 	}
 
+	onlyParam := &cli.StringSliceFlag{
+		Name:  "only",
+		Usage: "only process the setups with these names",
+	}
+
+	skipParam := &cli.StringSliceFlag{
+		Name:  "skip",
+		Usage: "skip the setups with these names",
+	}
+
+	profileParam := &cli.StringFlag{
+		Name:  "profile",
+		Usage: "apply the settings of this profile (like dev, staging or prod) from the config",
+	}
+
 	app := &cli.App{
 		Name:    "gowebbuild",
 		Usage:   "All in one tool to build web frontend projects.",
@@ -43,6 +60,15 @@ $ gowebbuild -c /path/to/gowebbuild.yaml watch
 Production build:
 $ gowebbuild build -p
 
+Build with the settings of the staging profile:
+$ gowebbuild build --profile staging
+
+Only build the setup named "admin":
+$ gowebbuild build --only admin
+
+Check the config file for mistakes:
+$ gowebbuild config validate
+
 Manually replace a string within some files (not limited to project directory):
 $ gowebbuild replace *.go foo bar
 `,
@@ -56,6 +82,79 @@ $ gowebbuild replace *.go foo bar
 				Action: tplAction,
 			},
 
+			{
+				Name:  "config",
+				Usage: "inspect the config file",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "validate",
+						Usage: "check the config file for unknown keys, wrong types, missing files and conflicting settings",
+						Flags: []cli.Flag{
+							cfgParam,
+							profileParam,
+						},
+						Action: configValidateAction,
+					},
+					{
+						Name:  "schema",
+						Usage: "print a JSON schema of the config file (e.g. for editor autocompletion)",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "o",
+								Usage: "write the schema to this file instead of stdout",
+							},
+						},
+						Action: configSchemaAction,
+					},
+					{
+						Name:  "migrate",
+						Usage: "convert a JSON or old-style config to the current YAML format and show the changes",
+						Flags: []cli.Flag{
+							cfgParam,
+							&cli.StringFlag{
+								Name:  "o",
+								Usage: "write the result to this file (default: the config file, or a .yaml file next to a JSON config)",
+							},
+							&cli.BoolFlag{
+								Name:    "write",
+								Aliases: []string{"w"},
+								Usage:   "save the result instead of only showing the diff",
+							},
+						},
+						Action: configMigrateAction,
+					},
+					{
+						Name:  "show",
+						Usage: "print the setups as gowebbuild uses them, with defaults, profiles and variables applied and paths resolved",
+						Flags: []cli.Flag{
+							cfgParam,
+							onlyParam,
+							skipParam,
+							profileParam,
+							&cli.BoolFlag{
+								Name:  "resolved",
+								Usage: "also print the build options that are handed to esbuild",
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Value: "yaml",
+								Usage: "output format, yaml or json",
+							},
+						},
+						Action: configShowAction,
+					},
+				},
+			},
+
+			{
+				Name:  "list",
+				Usage: "list the setups of the config file and what they do",
+				Flags: []cli.Flag{
+					cfgParam,
+				},
+				Action: listAction,
+			},
+
 			{
 				Name:  "npm-proxy",
 				Usage: "proxy npm packages",
@@ -70,20 +169,72 @@ $ gowebbuild replace *.go foo bar
 				Usage: "build web sources one time and exit",
 				Flags: []cli.Flag{
 					cfgParam,
+					onlyParam,
+					skipParam,
+					profileParam,
 					&cli.BoolFlag{
 						Name:  "p",
 						Value: false,
-						Usage: "use production ready build settings",
+						Usage: "use production ready build settings (alias for --profile prod)",
+					},
+					&cli.IntFlag{
+						Name:  "jobs",
+						Value: runtime.NumCPU(),
+						Usage: "number of setups that are built at the same time",
+					},
+					&cli.BoolFlag{
+						Name:  "keep-going",
+						Usage: "keep building the other setups when a setup fails",
+					},
+					&cli.BoolFlag{
+						Name:  "strict",
+						Usage: "treat warnings as errors",
+					},
+					&cli.StringFlag{
+						Name:  "report",
+						Usage: "write a machine-readable report of the build, format: json",
+					},
+					&cli.StringFlag{
+						Name:  "report-file",
+						Usage: "write the report to this file instead of stdout",
+					},
+					&cli.BoolFlag{
+						Name:  "metafile",
+						Usage: "write esbuild's metafile of setups that don't set metafile into gowebbuild's cache folder, for gowebbuild analyze",
 					},
 				},
 				Action: buildAction,
 			},
 
+			{
+				Name:      "analyze",
+				ArgsUsage: "[meta.json...]",
+				Usage:     "show what the output files of the setups consist of, based on their metafiles",
+				Flags: []cli.Flag{
+					cfgParam,
+					onlyParam,
+					skipParam,
+					&cli.IntFlag{
+						Name:  "top",
+						Value: 10,
+						Usage: "number of inputs listed per output file",
+					},
+					&cli.StringFlag{
+						Name:  "html",
+						Usage: "write a treemap of the output files to this HTML file",
+					},
+				},
+				Action: analyzeAction,
+			},
+
 			{
 				Name:  "watch",
 				Usage: "watch for changes and trigger the build",
 				Flags: []cli.Flag{
 					cfgParam,
+					profileParam,
+					onlyParam,
+					skipParam,
 					&cli.UintFlag{
 						Name: "lr-port",
 						Value: (func() uint {
@@ -115,6 +266,11 @@ $ gowebbuild replace *.go foo bar
 						Value: uint(lrserver.DefaultPort),
 						Usage: "port for the live reload server",
 					},
+					&cli.StringFlag{
+						Name:  "watch-backend",
+						Value: string(fswatch.BackendNative),
+						Usage: "how to detect changes for live reload: native or poll",
+					},
 				},
 				Action: func(ctx *cli.Context) error {
 					port := ctx.Uint("port")
@@ -123,31 +279,29 @@ $ gowebbuild replace *.go foo bar
 
 					if lrPort != 0 {
 						go func() {
-							w := watcher.New()
-							w.SetMaxEvents(1)
-							w.FilterOps(watcher.Write, watcher.Rename, watcher.Move, watcher.Create, watcher.Remove)
+							w, err := fswatch.New(fswatch.Options{
+								Backend: fswatch.Backend(ctx.String("watch-backend")),
+								Log:     func(msg string) { fmt.Println(msg) },
+							})
+							if err != nil {
+								fmt.Println(err.Error())
+								os.Exit(1)
+							}
 
-							if err := w.AddRecursive(root); err != nil {
+							if err := w.Add(root); err != nil {
 								fmt.Println(err.Error())
 								os.Exit(1)
 							}
 
-							go func() {
-								for {
-									select {
-									case event := <-w.Event:
-										fmt.Printf("File %s changed\n", event.Name())
-										triggerReload <- struct{}{}
-									case err := <-w.Error:
-										fmt.Println(err.Error())
-									case <-w.Closed:
-										return
-									}
+							for {
+								select {
+								case event := <-w.Events():
+									fmt.Printf("File %s changed\n", filepath.Base(event.Path))
+									drainEvents(w)
+									triggerReload <- struct{}{}
+								case err := <-w.Errors():
+									fmt.Println(err.Error())
 								}
-							}()
-
-							if err := w.Start(time.Millisecond * 100); err != nil {
-								fmt.Println(err.Error())
 							}
 						}()
 
@@ -169,7 +323,7 @@ $ gowebbuild replace *.go foo bar
 						}()
 					}
 
-					return Serve(root, port)
+					return Serve(ctx.Context, root, port)
 				},
 			},
 
@@ -178,6 +332,8 @@ $ gowebbuild replace *.go foo bar
 				Usage: "execute downloads as configured",
 				Flags: []cli.Flag{
 					cfgParam,
+					onlyParam,
+					skipParam,
 				},
 				Action: func(ctx *cli.Context) error {
 					cfgPath, err := filepath.Abs(ctx.String("c"))
@@ -187,10 +343,16 @@ $ gowebbuild replace *.go foo bar
 					}
 
 					os.Chdir(filepath.Dir(cfgPath))
-					opts := readCfg(cfgPath)
+					opts, err := gowebbuild.SelectSetups(readCfg(cfgPath), ctx.StringSlice("only"), ctx.StringSlice("skip"))
+					if err != nil {
+						return err
+					}
 
 					for i := range opts {
-						download(opts[i])
+						p := gowebbuild.New(opts[i], gowebbuild.WithName(gowebbuild.SetupName(opts[i], i)), gowebbuild.WithEventHandler(printEvent))
+						if err := p.RunStage(ctx.Context, gowebbuild.StageDownload); err != nil {
+							return err
+						}
 					}
 					return nil
 				},
@@ -213,20 +375,20 @@ $ gowebbuild replace *.go foo bar
 						return fmt.Errorf("invalid search string")
 					}
 
-					replace(options{
-						Replace: []struct {
-							Pattern string `yaml:"pattern"`
-							Search  string `yaml:"search"`
-							Replace string `yaml:"replace"`
-						}{
+					if strings.HasPrefix(replaceStr, "$") {
+						replaceStr = os.ExpandEnv(replaceStr)
+					}
+
+					p := gowebbuild.New(gowebbuild.Options{
+						Replace: []gowebbuild.ReplaceRule{
 							{
 								Pattern: files,
 								Search:  searchStr,
 								Replace: replaceStr,
 							},
 						},
-					})
-					return nil
+					}, gowebbuild.WithEventHandler(printEvent))
+					return p.RunStage(ctx.Context, gowebbuild.StageReplace)
 				},
 			},
 		},
@@ -245,5 +407,6 @@ $ gowebbuild replace *.go foo bar
 
 	if err := app.RunContext(appCtx, os.Args); err != nil {
 		fmt.Println(err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/trading-peter/gowebbuild/fsutils"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
+	"github.com/urfave/cli/v2"
+)
+
+// configMigrateAction converts a JSON or old-style config (integer enum values) to the current YAML format.
+// It prints a diff of the changes and only writes the result with --write.
+func configMigrateAction(ctx *cli.Context) error {
+	cfgPath := fsutils.ExpandPath(ctx.String("c"))
+
+	before, after, err := gowebbuild.MigrateConfig(cfgPath)
+	if err != nil {
+		return err
+	}
+
+	isJSON := filepath.Ext(cfgPath) == ".json"
+
+	dest := ctx.String("o")
+	if dest == "" {
+		dest = cfgPath
+		if isJSON {
+			dest = strings.TrimSuffix(cfgPath, ".json") + ".yaml"
+		}
+	}
+
+	if !isJSON && before == after {
+		fmt.Printf("%s is already up to date\n", cfgPath)
+		return nil
+	}
+
+	fmt.Print(unifiedDiff(cfgPath, dest, before, after))
+
+	if !ctx.Bool("write") {
+		fmt.Printf("\nRun again with --write to save the result to %s\n", dest)
+		return nil
+	}
+
+	if isJSON && fsutils.IsFile(dest) && dest != cfgPath {
+		return fmt.Errorf("%s already exists, choose another file with -o", dest)
+	}
+
+	if err := os.WriteFile(dest, []byte(after), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nWrote %s\n", dest)
+	return nil
+}
+
+// unifiedDiff returns a unified diff (with 3 lines of context) of the lines of a and b.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	out := strings.Builder{}
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+
+	const contextLines = 3
+
+	for start := 0; start < len(ops); {
+		// Find the next change.
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk until there are more than 2*context unchanged lines in a row.
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*contextLines {
+				break
+			}
+			end = next
+		}
+
+		from := max(start-contextLines, 0)
+		to := min(end+contextLines, len(ops))
+
+		countA, countB := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", ops[from].lineA+1, countA, ops[from].lineB+1, countB)
+		for _, op := range ops[from:to] {
+			line := op.text
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			fmt.Fprintf(&out, "%c%s", op.kind, line)
+		}
+
+		start = to
+	}
+
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+type diffOp struct {
+	kind         byte
+	text         string
+	lineA, lineB int
+}
+
+// diffLines computes the line operations turning a into b from their longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	return ops
+}
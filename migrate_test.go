@@ -0,0 +1,81 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		// want lists the operations as the kind followed by the line without its line break.
+		want []string
+	}{
+		{name: "empty", a: "", b: "", want: []string{}},
+		{name: "equal", a: "a\nb\n", b: "a\nb\n", want: []string{" a", " b"}},
+		{name: "added", a: "", b: "a\nb\n", want: []string{"+a", "+b"}},
+		{name: "removed", a: "a\nb\n", b: "", want: []string{"-a", "-b"}},
+		{name: "changed line", a: "a\nb\nc\n", b: "a\nx\nc\n", want: []string{" a", "-b", "+x", " c"}},
+		{name: "inserted in the middle", a: "a\nc\n", b: "a\nb\nc\n", want: []string{" a", "+b", " c"}},
+		{name: "moved line", a: "a\nb\nc\n", b: "b\nc\na\n", want: []string{"-a", " b", " c", "+a"}},
+		{name: "missing final newline", a: "a\nb", b: "a\nb\n", want: []string{" a", "-b", "+b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, op := range diffLines(splitLines(tt.a), splitLines(tt.b)) {
+				got = append(got, string(op.kind)+strings.TrimSuffix(op.text, "\n"))
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("diffLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	long := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "no changes",
+			a:    "a\n",
+			b:    "a\n",
+			want: "--- a\n+++ b\n",
+		},
+		{
+			name: "one hunk with context",
+			a:    long,
+			b:    strings.Replace(long, "6\n", "six\n", 1),
+			want: "--- a\n+++ b\n@@ -3,7 +3,7 @@\n 3\n 4\n 5\n-6\n+six\n 7\n 8\n 9\n",
+		},
+		{
+			name: "two hunks",
+			a:    long,
+			b:    strings.Replace(strings.Replace(long, "1\n", "one\n", 1), "12\n", "twelve\n", 1),
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			name: "close changes share a hunk",
+			a:    long,
+			b:    strings.Replace(strings.Replace(long, "4\n", "four\n", 1), "9\n", "nine\n", 1),
+			want: "--- a\n+++ b\n@@ -1,12 +1,12 @@\n 1\n 2\n 3\n-4\n+four\n 5\n 6\n 7\n 8\n-9\n+nine\n 10\n 11\n 12\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("a", "b", tt.a, tt.b); got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
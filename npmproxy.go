@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/trading-peter/gowebbuild/npmproxy"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
 	"github.com/urfave/cli/v2"
 )
 
@@ -25,8 +26,8 @@ func proxyAction(ctx *cli.Context) error {
 	return runProxy(ctx.Context, projectDir, opts)
 }
 
-func runProxy(ctx context.Context, projectDir string, opts []options) error {
-	overrides := []NpmProxyOverride{}
+func runProxy(ctx context.Context, projectDir string, opts []gowebbuild.Options) error {
+	overrides := []gowebbuild.NpmProxyOverride{}
 
 	for _, o := range opts {
 		overrides = append(overrides, o.NpmProxy.Overrides...)
@@ -0,0 +1,315 @@
+package gowebbuild
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ByteSize is a size in bytes. It can be configured as a number of bytes or with a unit, like 150kB, 1.5MB or 200KiB.
+type ByteSize int64
+
+var byteSizeRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+
+var byteSizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"kib": 1024,
+	"mib": 1024 * 1024,
+	"gib": 1024 * 1024 * 1024,
+}
+
+func (s *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	m := byteSizeRegex.FindStringSubmatch(strings.TrimSpace(node.Value))
+	if node.Kind != yaml.ScalarNode || m == nil {
+		return fmt.Errorf("line %d: invalid size %q, expected a number of bytes or a size like 150kB or 1.5MB", node.Line, node.Value)
+	}
+
+	unit, ok := byteSizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return fmt.Errorf("line %d: unknown size unit %q, expected one of B, kB, MB, GB, KiB, MiB or GiB", node.Line, m[2])
+	}
+
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	*s = ByteSize(n * unit)
+	return nil
+}
+
+func (ByteSize) jsonSchema() map[string]any {
+	return map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "integer", "minimum": 0},
+			map[string]any{"type": "string", "pattern": `^\d+(\.\d+)?\s*([kKmMgG]i?[bB]|[bB])?$`},
+			variableSchema,
+		},
+	}
+}
+
+// FormatSize formats a number of bytes for humans, like 1.5 kB.
+func FormatSize(size int64) string {
+	switch {
+	case size >= 1000*1000:
+		return fmt.Sprintf("%.1f MB", float64(size)/1000/1000)
+	case size >= 1000:
+		return fmt.Sprintf("%.1f kB", float64(size)/1000)
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// Budgets limits the size of the output files of a setup. They are checked by the budgets stage of production builds.
+type Budgets struct {
+	Files       []FileBudget       `yaml:"files" desc:"Limits for every output file matching a glob pattern."`
+	EntryPoints []EntryPointBudget `yaml:"entryPoints" desc:"Limits for the output files of an entry point (its JavaScript and CSS bundle)."`
+	Total       SizeLimit          `yaml:"total" desc:"Limits for all output files together."`
+}
+
+// SizeLimit is a maximum raw and gzipped size. Zero means no limit.
+type SizeLimit struct {
+	MaxSize ByteSize `yaml:"maxSize" desc:"Maximum size, as number of bytes or like 150kB."`
+	MaxGzip ByteSize `yaml:"maxGzip" desc:"Maximum gzipped size, as number of bytes or like 50kB."`
+}
+
+// FileBudget limits the size of each output file matching Pattern.
+type FileBudget struct {
+	Pattern string   `yaml:"pattern" path:"true" desc:"Glob pattern of the output files."`
+	MaxSize ByteSize `yaml:"maxSize" desc:"Maximum size of each file, as number of bytes or like 150kB."`
+	MaxGzip ByteSize `yaml:"maxGzip" desc:"Maximum gzipped size of each file, as number of bytes or like 50kB."`
+}
+
+// EntryPointBudget limits the size of the output files of an entry point.
+type EntryPointBudget struct {
+	EntryPoint string   `yaml:"entryPoint" path:"true" desc:"Entry point as configured in esbuild.entryPoints."`
+	MaxSize    ByteSize `yaml:"maxSize" desc:"Maximum size, as number of bytes or like 150kB."`
+	MaxGzip    ByteSize `yaml:"maxGzip" desc:"Maximum gzipped size, as number of bytes or like 50kB."`
+}
+
+func (b Budgets) empty() bool {
+	return len(b.Files) == 0 && len(b.EntryPoints) == 0 && b.Total == SizeLimit{}
+}
+
+// FileSize is the raw and gzipped size of an output file.
+type FileSize struct {
+	Path string
+	// LogicalPath is Path without the content hash (like dist/index.js for dist/index-5HQX2KJB.js), taken from the manifest.
+	// It's Path if the setup has no manifest or the manifest doesn't list the file.
+	LogicalPath string
+	Size        int64
+	Gzip        int64
+}
+
+// BudgetKind tells what a budget limits.
+type BudgetKind string
+
+const (
+	BudgetFile       BudgetKind = "file"
+	BudgetEntryPoint BudgetKind = "entryPoint"
+	BudgetTotal      BudgetKind = "total"
+)
+
+// BudgetResult is the outcome of checking a budget.
+type BudgetResult struct {
+	Kind BudgetKind
+	// Path is the output file or the entry point (empty for the total).
+	Path    string
+	Size    int64
+	Gzip    int64
+	MaxSize int64
+	MaxGzip int64
+}
+
+// Over reports whether the size or the gzipped size exceeds the budget.
+func (r BudgetResult) Over() bool {
+	return (r.MaxSize > 0 && r.Size > r.MaxSize) || (r.MaxGzip > 0 && r.Gzip > r.MaxGzip)
+}
+
+// BudgetError is returned by the budgets stage if output files are larger than their budgets.
+type BudgetError struct {
+	Over []BudgetResult
+}
+
+func (e *BudgetError) Error() string {
+	lines := []string{fmt.Sprintf("%d budget(s) exceeded", len(e.Over))}
+	for _, r := range e.Over {
+		label := string(r.Kind)
+		if r.Path != "" {
+			label += " " + r.Path
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s (limit %s), gzip %s (limit %s)", label, FormatSize(r.Size), FormatLimit(r.MaxSize), FormatSize(r.Gzip), FormatLimit(r.MaxGzip)))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// FormatLimit formats a budget limit for humans like FormatSize, a limit of 0 is "none".
+func FormatLimit(limit int64) string {
+	if limit == 0 {
+		return "none"
+	}
+
+	return FormatSize(limit)
+}
+
+// budgets measures the output files of the last esbuild run and checks them against the budgets of the setup.
+// The sizes and results are sent with an EventSizes event.
+func (p *Pipeline) budgets() error {
+	if p.result == nil {
+		return fmt.Errorf("no output files, the esbuild stage has to run first")
+	}
+
+	sizes := map[string]FileSize{}
+	paths := []string{}
+
+	// Hashed names change with every change of a file, the logical names let the sizes be compared between builds.
+	logical := map[string]string{}
+	if ManifestPath(p.Options) != "" {
+		outdir := p.Options.ESBuild.Outdir
+		for name, out := range p.outputManifest() {
+			logical[filepath.Join(outdir, filepath.FromSlash(out))] = filepath.Join(outdir, filepath.FromSlash(name))
+		}
+	}
+
+	for _, f := range p.result.OutputFiles {
+		// Source maps aren't downloaded by browsers in general, so they don't count.
+		if strings.HasSuffix(f.Path, ".map") {
+			continue
+		}
+
+		// Read the files again, later stages like replace may have changed them.
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			content = f.Contents
+		}
+
+		size, err := fileSize(f.Path, content)
+		if err != nil {
+			return err
+		}
+
+		size.LogicalPath = defaultString(logical[f.Path], f.Path)
+		sizes[f.Path] = size
+		paths = append(paths, f.Path)
+	}
+
+	sort.Strings(paths)
+
+	results := []BudgetResult{}
+	budgets := p.Options.Budgets
+
+	for _, b := range budgets.Files {
+		for _, path := range paths {
+			if ok, _ := filepath.Match(b.Pattern, path); !ok {
+				continue
+			}
+
+			results = append(results, BudgetResult{
+				Kind:    BudgetFile,
+				Path:    path,
+				Size:    sizes[path].Size,
+				Gzip:    sizes[path].Gzip,
+				MaxSize: int64(b.MaxSize),
+				MaxGzip: int64(b.MaxGzip),
+			})
+		}
+	}
+
+	for _, b := range budgets.EntryPoints {
+		r := BudgetResult{Kind: BudgetEntryPoint, Path: b.EntryPoint, MaxSize: int64(b.MaxSize), MaxGzip: int64(b.MaxGzip)}
+		for _, path := range p.entryPointOutputs(b.EntryPoint) {
+			r.Size += sizes[path].Size
+			r.Gzip += sizes[path].Gzip
+		}
+		results = append(results, r)
+	}
+
+	if budgets.Total != (SizeLimit{}) {
+		r := BudgetResult{Kind: BudgetTotal, MaxSize: int64(budgets.Total.MaxSize), MaxGzip: int64(budgets.Total.MaxGzip)}
+		for _, path := range paths {
+			r.Size += sizes[path].Size
+			r.Gzip += sizes[path].Gzip
+		}
+		results = append(results, r)
+	}
+
+	fileSizes := []FileSize{}
+	for _, path := range paths {
+		fileSizes = append(fileSizes, sizes[path])
+	}
+
+	p.emit(Event{Type: EventSizes, Stage: StageBudgets, Sizes: fileSizes, Budgets: results})
+
+	over := []BudgetResult{}
+	for _, r := range results {
+		if r.Over() {
+			over = append(over, r)
+		}
+	}
+
+	if len(over) > 0 {
+		return &BudgetError{Over: over}
+	}
+
+	return nil
+}
+
+// entryPointOutputs returns the absolute paths of the output files of an entry point, using the metafile.
+// The outputs of an HTML entry point are the ones of its scripts and stylesheets.
+func (p *Pipeline) entryPointOutputs(entryPoint string) []string {
+	meta := p.metafile()
+	if meta == nil {
+		return nil
+	}
+
+	entries := []string{entryPoint}
+	if isHTMLEntryPoint(entryPoint) {
+		entries = p.pageEntryPoints(entryPoint)
+	}
+
+	paths := []string{}
+	for out, o := range meta.Outputs {
+		if o.EntryPoint == "" || !slices.Contains(entries, p.absPath(o.EntryPoint)) {
+			continue
+		}
+
+		paths = append(paths, p.absPath(out))
+		if o.CSSBundle != "" {
+			paths = append(paths, p.absPath(o.CSSBundle))
+		}
+	}
+
+	return paths
+}
+
+func fileSize(path string, content []byte) (FileSize, error) {
+	buf := bytes.Buffer{}
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return FileSize{}, err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return FileSize{}, err
+	}
+
+	if err := w.Close(); err != nil {
+		return FileSize{}, err
+	}
+
+	return FileSize{Path: path, Size: int64(len(content)), Gzip: int64(buf.Len())}, nil
+}
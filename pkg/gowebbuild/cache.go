@@ -0,0 +1,24 @@
+package gowebbuild
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CacheDir returns the folder for files gowebbuild keeps between builds of the project in dir, like the default metafiles.
+// It's a folder per project in the user's cache folder, so these files end up neither in the output nor in the working tree.
+func CacheDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+
+	sum := sha256.Sum256([]byte(dir))
+	return filepath.Join(base, "gowebbuild", fmt.Sprintf("%s-%x", filepath.Base(dir), sum[:4]))
+}
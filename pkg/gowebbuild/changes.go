@@ -0,0 +1,423 @@
+package gowebbuild
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/trading-peter/gowebbuild/fsutils"
+)
+
+// plan is the part of the stages a run of RunChanges executes. Stages that aren't in stages are skipped,
+// copyRules and replaceRules are the indexes of the rules to apply (nil means all rules).
+type plan struct {
+	stages       map[Stage]string
+	copyRules    map[int]bool
+	replaceRules map[int]bool
+}
+
+// resolutionFiles change how esbuild resolves imports without being inputs of the build.
+var resolutionFiles = []string{"package.json", "tsconfig.json", "jsconfig.json"}
+
+// RunChanges runs only the stages the changed files (absolute paths) affect. A change of an input of the last esbuild run
+// rebuilds, a copy source applies its copy rule and a file matching a replace rule applies that rule again. Stages that write
+// into the output of other stages run after them (like the replace rules after a rebuild). Without changes, or as long as
+// no esbuild run succeeded, all stages run. The reason for every stage is reported as an info event.
+func (p *Pipeline) RunChanges(ctx context.Context, changes []string) error {
+	if len(changes) == 0 {
+		return p.Run(ctx)
+	}
+
+	p.stateMu.Lock()
+	pl := p.planChanges(changes)
+	if len(pl.stages) > 0 {
+		p.plan = pl
+	}
+	p.stateMu.Unlock()
+
+	if len(pl.stages) == 0 {
+		p.info("", fmt.Sprintf("No stage is affected by the change of %s", describeChanges(changes)))
+		return nil
+	}
+
+	defer func() {
+		p.stateMu.Lock()
+		p.plan = nil
+		p.stateMu.Unlock()
+	}()
+
+	for _, stage := range p.Stages {
+		reason, ok := pl.stages[stage]
+		if !ok {
+			continue
+		}
+
+		p.info(stage, fmt.Sprintf("Running %s: %s", stage, reason))
+		if err := p.RunStage(ctx, stage); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Affects reports whether changes affect what the running RunChanges (or Run) call does, so its result would be outdated.
+// Changes that only affect stages or rules the running call skips don't. It's safe to call while the pipeline runs.
+func (p *Pipeline) Affects(changes []string) bool {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	next := p.planChanges(changes)
+	running := p.plan
+
+	for stage := range next.stages {
+		// Run executes all stages with all rules.
+		if running == nil {
+			return true
+		}
+
+		if _, ok := running.stages[stage]; !ok {
+			continue
+		}
+
+		switch stage {
+		case StageCopy:
+			if rulesOverlap(running.copyRules, next.copyRules) {
+				return true
+			}
+		case StageReplace:
+			if rulesOverlap(running.replaceRules, next.replaceRules) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
+// rulesOverlap reports whether two sets of rule indexes share a rule, nil is the set of all rules.
+func rulesOverlap(a, b map[int]bool) bool {
+	if a == nil || b == nil {
+		return true
+	}
+
+	for i := range a {
+		if b[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// planChanges decides which stages and rules changes affect. p.stateMu must be held.
+func (p *Pipeline) planChanges(changes []string) *plan {
+	pl := &plan{stages: map[Stage]string{}, copyRules: map[int]bool{}, replaceRules: map[int]bool{}}
+
+	// The first reason of a stage is reported, unless a later one makes the stage do more (like all copy rules instead of one).
+	add := func(stage Stage, reason string) {
+		if _, ok := pl.stages[stage]; !ok && slices.Contains(p.Stages, stage) {
+			pl.stages[stage] = reason
+		}
+	}
+	set := func(stage Stage, reason string) {
+		if slices.Contains(p.Stages, stage) {
+			pl.stages[stage] = reason
+		}
+	}
+
+	for _, change := range changes {
+		if reason := p.buildReason(change); reason != "" {
+			add(StageESBuild, reason)
+		}
+
+		for i, op := range p.Options.Copy {
+			if isCopySource(op.Src, change) {
+				pl.copyRules[i] = true
+				add(StageCopy, fmt.Sprintf("%s is copied by copy rule %d", displayPath(change), i+1))
+			}
+		}
+
+		for i, op := range p.Options.Replace {
+			if matchesPattern(op.Pattern, change) {
+				pl.replaceRules[i] = true
+				add(StageReplace, fmt.Sprintf("%s matches replace rule %d", displayPath(change), i+1))
+			}
+		}
+	}
+
+	if _, ok := pl.stages[StageESBuild]; ok {
+		if p.Options.ESBuild.PurgeBeforeBuild {
+			set(StagePurge, "esbuild runs")
+		}
+
+		// The purge deletes the copied files as well.
+		if p.Options.ESBuild.PurgeBeforeBuild && len(p.Options.Copy) > 0 {
+			pl.copyRules = nil
+			set(StageCopy, "the output gets purged before esbuild runs")
+		}
+
+		if ManifestPath(p.Options) != "" {
+			set(StageManifest, "esbuild runs")
+
+			// Copied HTML files in the output point at the previous hashed files, they need to be copied again to be rewritten.
+			if pl.copyRules != nil {
+				for i, op := range p.Options.Copy {
+					if p.Options.ESBuild.Outdir != "" && isWithin(op.Dest, p.Options.ESBuild.Outdir) && copiesHTML(op.Src) {
+						pl.copyRules[i] = true
+						add(StageCopy, fmt.Sprintf("copy rule %d writes HTML that references the rebuilt files", i+1))
+					}
+				}
+			}
+		}
+
+		if p.Options.Watch.InjectLiveReload != "" {
+			set(StageInjectLiveReload, "esbuild runs")
+		}
+
+		if len(p.Options.Replace) > 0 {
+			pl.replaceRules = nil
+			set(StageReplace, "esbuild runs")
+		}
+
+		return pl
+	}
+
+	// Copied files can be the HTML file that gets the live reload script, HTML files of the output that reference
+	// hashed files or targets of replace rules.
+	for i := range pl.copyRules {
+		dest := p.Options.Copy[i].Dest
+
+		if ManifestPath(p.Options) != "" && p.Options.ESBuild.Outdir != "" && isWithin(dest, p.Options.ESBuild.Outdir) {
+			add(StageManifest, fmt.Sprintf("copy rule %d writes into the output folder", i+1))
+		}
+
+		if inject := p.Options.Watch.InjectLiveReload; inject != "" && isWithin(inject, dest) {
+			add(StageInjectLiveReload, fmt.Sprintf("copy rule %d writes %s", i+1, displayPath(inject)))
+		}
+
+		for j, op := range p.Options.Replace {
+			if pl.replaceRules[j] {
+				continue
+			}
+
+			matches, _ := filepath.Glob(op.Pattern)
+			if slices.ContainsFunc(matches, func(match string) bool { return isWithin(match, dest) }) {
+				pl.replaceRules[j] = true
+				add(StageReplace, fmt.Sprintf("copy rule %d writes files matching replace rule %d", i+1, j+1))
+			}
+		}
+	}
+
+	return pl
+}
+
+// buildReason returns why a change needs a rebuild, or an empty string if it doesn't affect the build.
+func (p *Pipeline) buildReason(change string) string {
+	if !slices.Contains(p.Stages, StageESBuild) {
+		return ""
+	}
+
+	if p.inputs == nil {
+		return "the inputs of the last build are unknown"
+	}
+
+	if p.inputs[change] {
+		return fmt.Sprintf("%s is an input of the build", displayPath(change))
+	}
+
+	for _, page := range p.pages {
+		if page.Path == change {
+			return fmt.Sprintf("%s is an HTML entry point", displayPath(change))
+		}
+	}
+
+	for _, swap := range p.Options.ContentSwap {
+		if swap.ReplaceWith == change {
+			return fmt.Sprintf("%s replaces the content of %s", displayPath(change), displayPath(swap.File))
+		}
+	}
+
+	if slices.Contains(resolutionFiles, filepath.Base(change)) || change == p.Options.ESBuild.Tsconfig {
+		return fmt.Sprintf("%s configures how imports are resolved", displayPath(change))
+	}
+
+	// A removed or renamed folder is reported without the files in it.
+	for input := range p.inputs {
+		if isWithin(input, change) {
+			return fmt.Sprintf("%s contains inputs of the build", displayPath(change))
+		}
+	}
+
+	return ""
+}
+
+// updateInputs remembers the input files of the last esbuild run, or forgets them if it failed. The inputs of the last
+// successful run and the files with errors are kept for WatchPaths.
+func (p *Pipeline) updateInputs(err error) {
+	var errorFiles []string
+	if err != nil && p.result != nil {
+		for _, msg := range p.result.Errors {
+			if msg.Location != nil && msg.Location.File != "" {
+				errorFiles = append(errorFiles, p.absPath(msg.Location.File))
+			}
+		}
+	}
+
+	var inputs map[string]bool
+	if meta := p.metafile(); err == nil && meta != nil {
+		inputs = make(map[string]bool, len(meta.Inputs))
+		for input := range meta.Inputs {
+			inputs[p.absPath(input)] = true
+		}
+	}
+
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	p.inputs = inputs
+	p.errorFiles = errorFiles
+	if inputs != nil {
+		p.watchInputs = inputs
+	}
+}
+
+// WatchPaths returns the files and folders whose changes affect the pipeline: the inputs of the last successful esbuild run
+// (without the ones in node_modules), the HTML entry points, the copy sources and the content swap files. The folders of
+// files with errors are added while the build fails, so new files next to them are noticed, and the folders of the entry points
+// as long as no build succeeded. Paths below other returned folders or below the configured watch paths are left out.
+func (p *Pipeline) WatchPaths() []string {
+	paths := []string{}
+	for input := range p.watchInputs {
+		if !isExcludedPath(input, "node_modules") && fsutils.IsFile(input) {
+			paths = append(paths, input)
+		}
+	}
+
+	for _, file := range p.errorFiles {
+		paths = append(paths, filepath.Dir(file))
+	}
+
+	if p.watchInputs == nil {
+		for _, entry := range p.Options.ESBuild.EntryPoints {
+			paths = append(paths, globBase(entry))
+		}
+
+		for _, entry := range p.Options.ESBuild.EntryPointsAdvanced {
+			paths = append(paths, filepath.Dir(entry.In))
+		}
+	}
+
+	for _, page := range p.pages {
+		paths = append(paths, page.Path)
+	}
+
+	for _, op := range p.Options.Copy {
+		matches, _ := filepath.Glob(op.Src)
+		paths = append(paths, matches...)
+	}
+
+	for _, swap := range p.Options.ContentSwap {
+		paths = append(paths, swap.File, swap.ReplaceWith)
+	}
+
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	// Sorted paths below a folder directly follow it.
+	result := []string{}
+	for _, path := range paths {
+		if len(result) > 0 && isWithin(path, result[len(result)-1]) {
+			continue
+		}
+
+		if slices.ContainsFunc(p.Options.Watch.Paths, func(dir string) bool { return isWithin(path, dir) }) {
+			continue
+		}
+
+		result = append(result, path)
+	}
+
+	return result
+}
+
+// globBase returns the folder of a glob pattern that doesn't contain any pattern characters.
+func globBase(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+
+	return dir
+}
+
+// isCopySource reports whether path is a source of a copy rule or inside of one.
+func isCopySource(src, path string) bool {
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if ok, _ := filepath.Match(src, dir); ok {
+			return true
+		}
+
+		if parent := filepath.Dir(dir); parent == dir {
+			return false
+		}
+	}
+}
+
+// copiesHTML reports whether a copy source is an HTML file or a folder containing one.
+func copiesHTML(src string) bool {
+	matches, _ := filepath.Glob(src)
+	for _, match := range matches {
+		found := false
+		filepath.WalkDir(match, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && isHTMLEntryPoint(path) {
+				found = true
+				return filepath.SkipAll
+			}
+			return nil
+		})
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesPattern(pattern, path string) bool {
+	ok, _ := filepath.Match(pattern, path)
+	return ok
+}
+
+// isWithin reports whether path is dir or below it.
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// displayPath shortens a path for messages by making it relative to the working directory.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+
+	return path
+}
+
+func describeChanges(changes []string) string {
+	if len(changes) == 1 {
+		return displayPath(changes[0])
+	}
+
+	return fmt.Sprintf("%d files", len(changes))
+}
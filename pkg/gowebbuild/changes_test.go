@@ -0,0 +1,308 @@
+package gowebbuild
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testPipeline loads cfg as the config of a project with an entry point, static files and an output folder,
+// and creates a watch mode pipeline for it that knows inputs (relative to the project) as the inputs of the last build.
+func testPipeline(t *testing.T, cfg string, inputs []string) (*Pipeline, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"gowebbuild.yaml":    "esbuild:\n  entryPoints: [src/index.ts]\n  outdir: dist\n" + cfg,
+		"src/index.ts":       "",
+		"static/index.html":  "<html></html>",
+		"static/logo.png":    "",
+		"static/img/bg.png":  "",
+		"dist/index.html":    "<html></html>",
+		"dist/index-AAAA.js": "",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := LoadConfig(filepath.Join(dir, "gowebbuild.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	p := New(loaded.Setups[0], WithStages(WatchStages()...))
+	if inputs != nil {
+		p.inputs = map[string]bool{}
+		for _, input := range inputs {
+			p.inputs[filepath.Join(dir, filepath.FromSlash(input))] = true
+		}
+	}
+
+	return p, dir
+}
+
+// describePlan lists the planned stages in the order they run, with the 1-based indexes of the applied rules.
+func describePlan(p *Pipeline, pl *plan) []string {
+	rules := func(indexes map[int]bool) string {
+		if indexes == nil {
+			return "[all]"
+		}
+
+		list := []string{}
+		for i := range indexes {
+			list = append(list, fmt.Sprint(i+1))
+		}
+		sort.Strings(list)
+		return "[" + strings.Join(list, ",") + "]"
+	}
+
+	stages := []string{}
+	for _, stage := range p.Stages {
+		if _, ok := pl.stages[stage]; !ok {
+			continue
+		}
+
+		switch stage {
+		case StageCopy:
+			stages = append(stages, string(stage)+rules(pl.copyRules))
+		case StageReplace:
+			stages = append(stages, string(stage)+rules(pl.replaceRules))
+		default:
+			stages = append(stages, string(stage))
+		}
+	}
+
+	return stages
+}
+
+const (
+	copyStatic = `
+copy:
+  - {src: static/logo.png, dest: dist/logo.png}
+  - {src: static/index.html, dest: dist/index.html}
+`
+	copyOutside = `
+copy:
+  - {src: static/logo.png, dest: public/logo.png}
+  - {src: static/img, dest: public/img}
+`
+	replaceHTML = `
+replace:
+  - {pattern: dist/*.html, search: a, replace: b}
+`
+)
+
+func TestPlanChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     string
+		inputs  []string
+		changes []string
+		want    []string
+	}{
+		{
+			name:    "inputs unknown",
+			changes: []string{"README.md"},
+			want:    []string{"esbuild"},
+		},
+		{
+			name:    "input",
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"src/index.ts"},
+			want:    []string{"esbuild"},
+		},
+		{
+			name:    "unrelated file",
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"README.md"},
+			want:    []string{},
+		},
+		{
+			name:    "resolution file",
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"package.json"},
+			want:    []string{"esbuild"},
+		},
+		{
+			name:    "folder with inputs",
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"src"},
+			want:    []string{"esbuild"},
+		},
+		{
+			name:    "copy source",
+			cfg:     copyOutside,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"static/logo.png"},
+			want:    []string{"copy[1]"},
+		},
+		{
+			name:    "file in a copied folder",
+			cfg:     copyOutside,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"static/img/bg.png", "static/logo.png"},
+			want:    []string{"copy[1,2]"},
+		},
+		{
+			name:    "input and copy source",
+			cfg:     copyOutside,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"src/index.ts", "static/logo.png"},
+			want:    []string{"copy[1]", "esbuild"},
+		},
+		{
+			name:    "purge before build",
+			cfg:     "  purgeBeforeBuild: true\n" + copyOutside,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"src/index.ts"},
+			want:    []string{"purge", "copy[all]", "esbuild"},
+		},
+		{
+			name:    "copy into the output with a manifest",
+			cfg:     "hashNames: true\n" + copyStatic,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"static/logo.png"},
+			want:    []string{"copy[1]", "manifest"},
+		},
+		{
+			name:    "rebuild copies HTML again for the manifest",
+			cfg:     "hashNames: true\n" + copyStatic,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"src/index.ts"},
+			want:    []string{"copy[2]", "esbuild", "manifest"},
+		},
+		{
+			name:    "rebuild without a manifest",
+			cfg:     copyStatic,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"src/index.ts"},
+			want:    []string{"esbuild"},
+		},
+		{
+			name:    "rebuild applies all replace rules",
+			cfg:     replaceHTML,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"src/index.ts"},
+			want:    []string{"esbuild", "replace[all]"},
+		},
+		{
+			name:    "file matching a replace rule",
+			cfg:     replaceHTML,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"dist/index.html"},
+			want:    []string{"replace[1]"},
+		},
+		{
+			name:    "copy writes a file of a replace rule",
+			cfg:     copyStatic + replaceHTML,
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"static/index.html"},
+			want:    []string{"copy[2]", "replace[1]"},
+		},
+		{
+			name:    "copy writes the live reload HTML file",
+			cfg:     copyStatic + "watch:\n  injectLiveReload: dist/index.html\n",
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"static/index.html"},
+			want:    []string{"copy[2]", "inject-live-reload"},
+		},
+		{
+			name:    "rebuild injects the live reload script",
+			cfg:     "watch:\n  injectLiveReload: dist/index.html\n",
+			inputs:  []string{"src/index.ts"},
+			changes: []string{"src/index.ts"},
+			want:    []string{"esbuild", "inject-live-reload"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, dir := testPipeline(t, tt.cfg, tt.inputs)
+
+			changes := []string{}
+			for _, change := range tt.changes {
+				changes = append(changes, filepath.Join(dir, filepath.FromSlash(change)))
+			}
+
+			if got := describePlan(p, p.planChanges(changes)); !slices.Equal(got, tt.want) {
+				t.Errorf("planChanges(%v) = %v, want %v", tt.changes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAffects(t *testing.T) {
+	tests := []struct {
+		name    string
+		running []string
+		changes []string
+		want    bool
+	}{
+		{name: "full build", running: nil, changes: []string{"static/logo.png"}, want: true},
+		{name: "full build and unrelated file", running: nil, changes: []string{"README.md"}, want: false},
+		{name: "same copy rule", running: []string{"static/logo.png"}, changes: []string{"static/logo.png"}, want: true},
+		{name: "other copy rule", running: []string{"static/logo.png"}, changes: []string{"static/img/bg.png"}, want: false},
+		{name: "input during a copy", running: []string{"static/logo.png"}, changes: []string{"src/index.ts"}, want: false},
+		{name: "input during a rebuild", running: []string{"src/index.ts"}, changes: []string{"src/index.ts"}, want: true},
+		{name: "copy source during a rebuild", running: []string{"src/index.ts"}, changes: []string{"static/logo.png"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, dir := testPipeline(t, copyOutside, []string{"src/index.ts"})
+			abs := func(paths []string) []string {
+				list := []string{}
+				for _, path := range paths {
+					list = append(list, filepath.Join(dir, filepath.FromSlash(path)))
+				}
+				return list
+			}
+
+			if tt.running != nil {
+				p.plan = p.planChanges(abs(tt.running))
+			}
+
+			if got := p.Affects(abs(tt.changes)); got != tt.want {
+				t.Errorf("Affects(%v) while building %v = %t, want %t", tt.changes, tt.running, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  string
+		want []string
+	}{
+		{name: "copy sources", cfg: copyOutside, want: []string{"src", "static/img", "static/logo.png"}},
+		{name: "below watch paths", cfg: copyOutside + "watch:\n  paths: [static]\n", want: []string{"src"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, dir := testPipeline(t, tt.cfg, nil)
+
+			got := []string{}
+			for _, path := range p.WatchPaths() {
+				rel, _ := filepath.Rel(dir, path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("WatchPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package gowebbuild
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+)
+
+// Compress writes precompressed .gz and .br files next to the output files of production builds,
+// for servers that deliver them to clients accepting the encoding.
+type Compress struct {
+	Gzip        bool     `yaml:"gzip" desc:"Write a .gz file next to every compressible output file."`
+	Brotli      bool     `yaml:"brotli" desc:"Write a .br file next to every compressible output file."`
+	Threshold   ByteSize `yaml:"threshold" desc:"Files smaller than this aren't compressed (default 1kB)."`
+	GzipLevel   int      `yaml:"gzipLevel" desc:"gzip compression level from 1 to 9 (0 or unset for the default 9)."`
+	BrotliLevel int      `yaml:"brotliLevel" desc:"brotli compression level from 1 to 11 (0 or unset for the default 11)."`
+}
+
+func (c Compress) enabled() bool {
+	return c.Gzip || c.Brotli
+}
+
+// defaultCompressThreshold is the default of Compress.Threshold. Smaller files hardly get smaller.
+const defaultCompressThreshold = 1000
+
+// compressibleExtensions are the output files that are compressed. Images (other than SVG) and fonts are compressed already.
+var compressibleExtensions = map[string]bool{
+	".js":   true,
+	".mjs":  true,
+	".cjs":  true,
+	".css":  true,
+	".html": true,
+	".htm":  true,
+	".svg":  true,
+	".json": true,
+	".wasm": true,
+}
+
+// compressor is a precompressed encoding: the extension of its files and how to write them.
+type compressor struct {
+	name      string
+	ext       string
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+// compress writes the .gz and .br files of the compressible files in the output folder (or of the output file).
+// Files that are below the threshold or don't get smaller lose the compressed files of earlier builds,
+// so servers never deliver outdated content.
+func (p *Pipeline) compress() error {
+	opts := p.Options.Compress
+	if !opts.enabled() {
+		return nil
+	}
+
+	threshold := int64(opts.Threshold)
+	if threshold == 0 {
+		threshold = defaultCompressThreshold
+	}
+
+	gzipLevel := opts.GzipLevel
+	if gzipLevel == 0 {
+		gzipLevel = gzip.BestCompression
+	}
+
+	brotliLevel := opts.BrotliLevel
+	if brotliLevel == 0 {
+		brotliLevel = brotli.BestCompression
+	}
+
+	compressors := []compressor{}
+	if opts.Gzip {
+		compressors = append(compressors, compressor{name: "gzip", ext: ".gz", newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, gzipLevel)
+		}})
+	}
+	if opts.Brotli {
+		compressors = append(compressors, compressor{name: "brotli", ext: ".br", newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return brotli.NewWriterLevel(w, brotliLevel), nil
+		}})
+	}
+
+	files, err := p.compressibleFiles()
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		sizes := []string{}
+		for _, c := range compressors {
+			dest := file + c.ext
+
+			var compressed []byte
+			if int64(len(content)) >= threshold {
+				if compressed, err = compressBytes(content, c); err != nil {
+					return fmt.Errorf("failed to compress %s: %w", file, err)
+				}
+			}
+
+			if compressed == nil || len(compressed) >= len(content) {
+				if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				continue
+			}
+
+			if err := os.WriteFile(dest, compressed, 0644); err != nil {
+				return err
+			}
+
+			sizes = append(sizes, fmt.Sprintf("%s %s", c.name, FormatSize(int64(len(compressed)))))
+		}
+
+		if len(sizes) == 0 {
+			continue
+		}
+
+		total++
+		p.emit(Event{
+			Type:    EventFileWritten,
+			Stage:   StageCompress,
+			Path:    file,
+			Count:   len(sizes),
+			Message: fmt.Sprintf("Compressed %s (%s): %s", file, FormatSize(int64(len(content))), strings.Join(sizes, ", ")),
+		})
+	}
+
+	p.info(StageCompress, fmt.Sprintf("Compressed %d of %d file(s)", total, len(files)))
+	return nil
+}
+
+// compressibleFiles returns the compressible files in the output folder, including the ones copied there
+// and HTML entry points, or the output files of esbuild if the setup uses outfile.
+func (p *Pipeline) compressibleFiles() ([]string, error) {
+	files := []string{}
+	// A metafile written into the output folder isn't meant to be served.
+	metafile := MetafilePath(p.Options)
+	add := func(path string) {
+		if compressibleExtensions[strings.ToLower(filepath.Ext(path))] && path != metafile {
+			files = append(files, path)
+		}
+	}
+
+	outdir := p.Options.ESBuild.Outdir
+	if outdir == "" {
+		if p.result != nil {
+			for _, f := range p.result.OutputFiles {
+				add(f.Path)
+			}
+		}
+		return files, nil
+	}
+
+	err := filepath.WalkDir(outdir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			add(path)
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+func compressBytes(content []byte, c compressor) ([]byte, error) {
+	buf := bytes.Buffer{}
+	w, err := c.newWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
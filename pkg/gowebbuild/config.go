@@ -0,0 +1,81 @@
+package gowebbuild
+
+type loadConfig struct {
+	profile  string
+	validate bool
+}
+
+// LoadOption configures how LoadConfig reads a config file.
+type LoadOption func(*loadConfig)
+
+// WithProfile merges the named profile over every setup. The prod profile is always available.
+func WithProfile(profile string) LoadOption {
+	return func(c *loadConfig) {
+		c.profile = profile
+	}
+}
+
+// WithValidation checks the config against the Options schema and for common mistakes (missing entry points, etc.).
+// Problems are returned as ConfigErrors.
+func WithValidation() LoadOption {
+	return func(c *loadConfig) {
+		c.validate = true
+	}
+}
+
+// Config is a loaded config file.
+type Config struct {
+	// Path of the config file.
+	Path string
+	// Setups with defaults, extends, the profile and variables applied and all paths made absolute.
+	Setups []Options
+	// Files the config was read from: the config file itself, the files it extends or includes and .env files.
+	Files []string
+	// Warnings are problems that don't stop the config from loading, like variables that aren't set.
+	Warnings ConfigErrors
+}
+
+// LoadConfig reads a YAML or JSON config file.
+func LoadConfig(path string, options ...LoadOption) (*Config, error) {
+	c := &loadConfig{}
+	for _, option := range options {
+		option(c)
+	}
+
+	doc, err := resolveCfg(path, c.profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.validate {
+		if errs := checkCfgSchema(doc); len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	optsSetups := []Options{}
+
+	for _, node := range doc.setups {
+		opt := Options{}
+		if err := node.Decode(&opt); err != nil {
+			return nil, err
+		}
+
+		// Profiles are already applied to the node.
+		opt.Profiles = nil
+
+		// Process all paths in each options setup
+		if err := processPaths(&opt); err != nil {
+			return nil, err
+		}
+		optsSetups = append(optsSetups, opt)
+	}
+
+	if c.validate {
+		if errs := checkCfgSetups(doc, optsSetups); len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	return &Config{Path: path, Setups: optsSetups, Files: doc.sourceFiles(), Warnings: doc.warnings}, nil
+}
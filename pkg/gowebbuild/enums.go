@@ -0,0 +1,583 @@
+package gowebbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"gopkg.in/yaml.v3"
+)
+
+// The types in this file let the config refer to esbuild's enum values by name (like `format: esm`)
+// instead of the raw integers of the esbuild API. Format, platform, sourcemap, target and logLevel
+// were configured as integers in older configs, so those still accept the integer form too.
+
+var formatNames = map[string]api.Format{
+	"default":  api.FormatDefault,
+	"iife":     api.FormatIIFE,
+	"cjs":      api.FormatCommonJS,
+	"commonjs": api.FormatCommonJS,
+	"esm":      api.FormatESModule,
+}
+
+var platformNames = map[string]api.Platform{
+	"default": api.PlatformDefault,
+	"browser": api.PlatformBrowser,
+	"node":    api.PlatformNode,
+	"neutral": api.PlatformNeutral,
+}
+
+var sourceMapNames = map[string]api.SourceMap{
+	"none":                api.SourceMapNone,
+	"inline":              api.SourceMapInline,
+	"linked":              api.SourceMapLinked,
+	"external":            api.SourceMapExternal,
+	"both":                api.SourceMapInlineAndExternal,
+	"inline-and-external": api.SourceMapInlineAndExternal,
+}
+
+var logLevelNames = map[string]api.LogLevel{
+	"silent":  api.LogLevelSilent,
+	"verbose": api.LogLevelVerbose,
+	"debug":   api.LogLevelDebug,
+	"info":    api.LogLevelInfo,
+	"warning": api.LogLevelWarning,
+	"error":   api.LogLevelError,
+}
+
+var targetNames = map[string]api.Target{
+	"esnext": api.ESNext,
+	"es5":    api.ES5,
+	"es6":    api.ES2015,
+	"es2015": api.ES2015,
+	"es2016": api.ES2016,
+	"es2017": api.ES2017,
+	"es2018": api.ES2018,
+	"es2019": api.ES2019,
+	"es2020": api.ES2020,
+	"es2021": api.ES2021,
+	"es2022": api.ES2022,
+	"es2023": api.ES2023,
+	"es2024": api.ES2024,
+}
+
+var engineNames = map[string]api.EngineName{
+	"chrome":  api.EngineChrome,
+	"deno":    api.EngineDeno,
+	"edge":    api.EngineEdge,
+	"firefox": api.EngineFirefox,
+	"hermes":  api.EngineHermes,
+	"ie":      api.EngineIE,
+	"ios":     api.EngineIOS,
+	"node":    api.EngineNode,
+	"opera":   api.EngineOpera,
+	"rhino":   api.EngineRhino,
+	"safari":  api.EngineSafari,
+}
+
+var engineRegex = regexp.MustCompile(`^([a-z]+)(\d[0-9.]*)$`)
+
+var loaderNames = map[string]api.Loader{
+	"none":       api.LoaderNone,
+	"base64":     api.LoaderBase64,
+	"binary":     api.LoaderBinary,
+	"copy":       api.LoaderCopy,
+	"css":        api.LoaderCSS,
+	"dataurl":    api.LoaderDataURL,
+	"default":    api.LoaderDefault,
+	"empty":      api.LoaderEmpty,
+	"file":       api.LoaderFile,
+	"global-css": api.LoaderGlobalCSS,
+	"js":         api.LoaderJS,
+	"json":       api.LoaderJSON,
+	"jsx":        api.LoaderJSX,
+	"local-css":  api.LoaderLocalCSS,
+	"text":       api.LoaderText,
+	"ts":         api.LoaderTS,
+	"tsx":        api.LoaderTSX,
+}
+
+var legalCommentsNames = map[string]api.LegalComments{
+	"default":  api.LegalCommentsDefault,
+	"none":     api.LegalCommentsNone,
+	"inline":   api.LegalCommentsInline,
+	"eof":      api.LegalCommentsEndOfFile,
+	"linked":   api.LegalCommentsLinked,
+	"external": api.LegalCommentsExternal,
+}
+
+var jsxNames = map[string]api.JSX{
+	"transform": api.JSXTransform,
+	"preserve":  api.JSXPreserve,
+	"automatic": api.JSXAutomatic,
+}
+
+var charsetNames = map[string]api.Charset{
+	"default": api.CharsetDefault,
+	"ascii":   api.CharsetASCII,
+	"utf8":    api.CharsetUTF8,
+}
+
+var packagesNames = map[string]api.Packages{
+	"default":  api.PackagesDefault,
+	"bundle":   api.PackagesBundle,
+	"external": api.PackagesExternal,
+}
+
+var dropNames = map[string]api.Drop{
+	"console":  api.DropConsole,
+	"debugger": api.DropDebugger,
+}
+
+type Format api.Format
+
+func (e *Format) UnmarshalYAML(node *yaml.Node) error {
+	return decodeLegacyEnumYAML(node, "format", formatNames, (*api.Format)(e))
+}
+
+func (e *Format) UnmarshalJSON(data []byte) error {
+	return decodeLegacyEnumJSON(data, "format", formatNames, (*api.Format)(e))
+}
+
+func (e Format) MarshalYAML() (any, error) {
+	return enumName(formatNames, api.Format(e)), nil
+}
+
+func (e Format) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enumName(formatNames, api.Format(e)))
+}
+
+type Platform api.Platform
+
+func (e *Platform) UnmarshalYAML(node *yaml.Node) error {
+	return decodeLegacyEnumYAML(node, "platform", platformNames, (*api.Platform)(e))
+}
+
+func (e *Platform) UnmarshalJSON(data []byte) error {
+	return decodeLegacyEnumJSON(data, "platform", platformNames, (*api.Platform)(e))
+}
+
+func (e Platform) MarshalYAML() (any, error) {
+	return enumName(platformNames, api.Platform(e)), nil
+}
+
+func (e Platform) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enumName(platformNames, api.Platform(e)))
+}
+
+// SourceMap additionally accepts booleans like the esbuild CLI does: `true` means linked, `false` means none.
+type SourceMap api.SourceMap
+
+func (e *SourceMap) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!bool" {
+		var b bool
+		if err := node.Decode(&b); err != nil {
+			return err
+		}
+		*e = SourceMap(sourceMapFromBool(b))
+		return nil
+	}
+
+	return decodeLegacyEnumYAML(node, "sourcemap", sourceMapNames, (*api.SourceMap)(e))
+}
+
+func (e *SourceMap) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*e = SourceMap(sourceMapFromBool(b))
+		return nil
+	}
+
+	return decodeLegacyEnumJSON(data, "sourcemap", sourceMapNames, (*api.SourceMap)(e))
+}
+
+func (e SourceMap) MarshalYAML() (any, error) {
+	return enumName(sourceMapNames, api.SourceMap(e)), nil
+}
+
+func (e SourceMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enumName(sourceMapNames, api.SourceMap(e)))
+}
+
+func sourceMapFromBool(b bool) api.SourceMap {
+	if b {
+		return api.SourceMapLinked
+	}
+	return api.SourceMapNone
+}
+
+type LogLevel api.LogLevel
+
+func (e *LogLevel) UnmarshalYAML(node *yaml.Node) error {
+	return decodeLegacyEnumYAML(node, "logLevel", logLevelNames, (*api.LogLevel)(e))
+}
+
+func (e *LogLevel) UnmarshalJSON(data []byte) error {
+	return decodeLegacyEnumJSON(data, "logLevel", logLevelNames, (*api.LogLevel)(e))
+}
+
+func (e LogLevel) MarshalYAML() (any, error) {
+	return enumName(logLevelNames, api.LogLevel(e)), nil
+}
+
+func (e LogLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enumName(logLevelNames, api.LogLevel(e)))
+}
+
+// Target holds a language target and/or a list of engines, just like esbuild's `--target=es2020,chrome58,firefox57`.
+// It can be configured as a single name (`target: es2022`), a comma separated string, a list of names
+// (`target: [es2020, chrome100, safari15.4]`) or the legacy integer value.
+type Target struct {
+	Target  api.Target
+	Engines []api.Engine
+}
+
+func (e *Target) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!int" {
+		return decodeLegacyEnumYAML(node, "target", targetNames, &e.Target)
+	}
+
+	list := []string{}
+	if node.Kind == yaml.ScalarNode {
+		list = strings.Split(node.Value, ",")
+	} else if err := node.Decode(&list); err != nil {
+		return err
+	}
+
+	if err := e.fromNames(list); err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	return nil
+}
+
+func (e *Target) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		return e.fromNames(strings.Split(name, ","))
+	}
+
+	list := []string{}
+	if err := json.Unmarshal(data, &list); err == nil {
+		return e.fromNames(list)
+	}
+
+	return decodeLegacyEnumJSON(data, "target", targetNames, &e.Target)
+}
+
+func (e Target) MarshalYAML() (any, error) {
+	names := e.names()
+
+	switch len(names) {
+	case 0:
+		return nil, nil
+	case 1:
+		return names[0], nil
+	default:
+		return names, nil
+	}
+}
+
+func (e Target) MarshalJSON() ([]byte, error) {
+	v, _ := e.MarshalYAML()
+	return json.Marshal(v)
+}
+
+func (e *Target) fromNames(list []string) error {
+	*e = Target{}
+
+	for _, name := range list {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		if t, ok := targetNames[name]; ok {
+			e.Target = t
+			continue
+		}
+
+		m := engineRegex.FindStringSubmatch(name)
+		if m == nil {
+			return fmt.Errorf("unknown target value %q, expected one of: %s or an engine with version like chrome100 or safari15.4", name, strings.Join(sortedEnumNames(targetNames), ", "))
+		}
+
+		engine, err := lookupEnum("target engine", m[1], engineNames)
+		if err != nil {
+			return err
+		}
+
+		e.Engines = append(e.Engines, api.Engine{Name: engine, Version: m[2]})
+	}
+
+	return nil
+}
+
+func (e Target) names() []string {
+	names := []string{}
+
+	if e.Target != api.DefaultTarget {
+		names = append(names, enumName(targetNames, e.Target))
+	}
+
+	for _, engine := range e.Engines {
+		names = append(names, enumName(engineNames, engine.Name)+engine.Version)
+	}
+
+	return names
+}
+
+type Loader api.Loader
+
+func (e *Loader) UnmarshalYAML(node *yaml.Node) error {
+	return decodeEnumYAML(node, "loader", loaderNames, (*api.Loader)(e))
+}
+
+func (e *Loader) UnmarshalJSON(data []byte) error {
+	return decodeEnumJSON(data, "loader", loaderNames, (*api.Loader)(e))
+}
+
+func (e Loader) MarshalYAML() (any, error) {
+	return enumName(loaderNames, api.Loader(e)), nil
+}
+
+func (e Loader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enumName(loaderNames, api.Loader(e)))
+}
+
+type LegalComments api.LegalComments
+
+func (e *LegalComments) UnmarshalYAML(node *yaml.Node) error {
+	return decodeEnumYAML(node, "legalComments", legalCommentsNames, (*api.LegalComments)(e))
+}
+
+func (e *LegalComments) UnmarshalJSON(data []byte) error {
+	return decodeEnumJSON(data, "legalComments", legalCommentsNames, (*api.LegalComments)(e))
+}
+
+func (e LegalComments) MarshalYAML() (any, error) {
+	return enumName(legalCommentsNames, api.LegalComments(e)), nil
+}
+
+func (e LegalComments) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enumName(legalCommentsNames, api.LegalComments(e)))
+}
+
+type JSX api.JSX
+
+func (e *JSX) UnmarshalYAML(node *yaml.Node) error {
+	return decodeEnumYAML(node, "jsx", jsxNames, (*api.JSX)(e))
+}
+
+func (e *JSX) UnmarshalJSON(data []byte) error {
+	return decodeEnumJSON(data, "jsx", jsxNames, (*api.JSX)(e))
+}
+
+func (e JSX) MarshalYAML() (any, error) {
+	return enumName(jsxNames, api.JSX(e)), nil
+}
+
+func (e JSX) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enumName(jsxNames, api.JSX(e)))
+}
+
+type Charset api.Charset
+
+func (e *Charset) UnmarshalYAML(node *yaml.Node) error {
+	return decodeEnumYAML(node, "charset", charsetNames, (*api.Charset)(e))
+}
+
+func (e *Charset) UnmarshalJSON(data []byte) error {
+	return decodeEnumJSON(data, "charset", charsetNames, (*api.Charset)(e))
+}
+
+func (e Charset) MarshalYAML() (any, error) {
+	return enumName(charsetNames, api.Charset(e)), nil
+}
+
+func (e Charset) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enumName(charsetNames, api.Charset(e)))
+}
+
+type Packages api.Packages
+
+func (e *Packages) UnmarshalYAML(node *yaml.Node) error {
+	return decodeEnumYAML(node, "packages", packagesNames, (*api.Packages)(e))
+}
+
+func (e *Packages) UnmarshalJSON(data []byte) error {
+	return decodeEnumJSON(data, "packages", packagesNames, (*api.Packages)(e))
+}
+
+func (e Packages) MarshalYAML() (any, error) {
+	return enumName(packagesNames, api.Packages(e)), nil
+}
+
+func (e Packages) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enumName(packagesNames, api.Packages(e)))
+}
+
+// Drop is configured as a list of names (`drop: [console, debugger]`) that get combined into esbuild's bit set.
+type Drop api.Drop
+
+func (e *Drop) UnmarshalYAML(node *yaml.Node) error {
+	list := []string{}
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+
+	return e.fromNames(list)
+}
+
+func (e *Drop) UnmarshalJSON(data []byte) error {
+	list := []string{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	return e.fromNames(list)
+}
+
+func (e Drop) MarshalYAML() (any, error) {
+	return e.names(), nil
+}
+
+func (e Drop) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.names())
+}
+
+func (e *Drop) fromNames(list []string) error {
+	*e = 0
+	for _, name := range list {
+		v, err := lookupEnum("drop", name, dropNames)
+		if err != nil {
+			return err
+		}
+		*e |= Drop(v)
+	}
+
+	return nil
+}
+
+func (e Drop) names() []string {
+	list := []string{}
+	for _, name := range sortedEnumNames(dropNames) {
+		if api.Drop(e)&dropNames[name] != 0 {
+			list = append(list, name)
+		}
+	}
+
+	return list
+}
+
+func decodeEnumYAML[T comparable](node *yaml.Node, kind string, names map[string]T, dst *T) error {
+	var name string
+	if err := node.Decode(&name); err != nil {
+		return err
+	}
+
+	v, err := lookupEnum(kind, name, names)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	*dst = v
+	return nil
+}
+
+// decodeLegacyEnumYAML works like decodeEnumYAML, but also accepts the raw integer values that older configs used.
+func decodeLegacyEnumYAML[T ~uint8](node *yaml.Node, kind string, names map[string]T, dst *T) error {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!int" {
+		var i int
+		if err := node.Decode(&i); err != nil {
+			return err
+		}
+
+		v, err := lookupEnumInt(kind, i, names)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+
+		*dst = v
+		return nil
+	}
+
+	return decodeEnumYAML(node, kind, names, dst)
+}
+
+func decodeLegacyEnumJSON[T ~uint8](data []byte, kind string, names map[string]T, dst *T) error {
+	var i int
+	if err := json.Unmarshal(data, &i); err == nil {
+		v, err := lookupEnumInt(kind, i, names)
+		if err != nil {
+			return err
+		}
+
+		*dst = v
+		return nil
+	}
+
+	return decodeEnumJSON(data, kind, names, dst)
+}
+
+func decodeEnumJSON[T comparable](data []byte, kind string, names map[string]T, dst *T) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	v, err := lookupEnum(kind, name, names)
+	if err != nil {
+		return err
+	}
+
+	*dst = v
+	return nil
+}
+
+func lookupEnum[T comparable](kind, name string, names map[string]T) (T, error) {
+	if v, ok := names[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return v, nil
+	}
+
+	var zero T
+	return zero, fmt.Errorf("unknown %s value %q, expected one of: %s", kind, name, strings.Join(sortedEnumNames(names), ", "))
+}
+
+func lookupEnumInt[T ~uint8](kind string, i int, names map[string]T) (T, error) {
+	for _, v := range names {
+		if int(v) == i {
+			return v, nil
+		}
+	}
+
+	// The zero value always means "use esbuild's default", even if it has no name of its own.
+	if i == 0 {
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("unknown %s value %d, expected one of: %s", kind, i, strings.Join(sortedEnumNames(names), ", "))
+}
+
+func enumName[T comparable](names map[string]T, v T) string {
+	for _, name := range sortedEnumNames(names) {
+		if names[name] == v {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func sortedEnumNames[T comparable](names map[string]T) []string {
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return list
+}
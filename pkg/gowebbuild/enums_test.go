@@ -0,0 +1,102 @@
+package gowebbuild
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"gopkg.in/yaml.v3"
+)
+
+type enumFields struct {
+	Format    Format    `yaml:"format" json:"format"`
+	Platform  Platform  `yaml:"platform" json:"platform"`
+	Sourcemap SourceMap `yaml:"sourcemap" json:"sourcemap"`
+	LogLevel  LogLevel  `yaml:"logLevel" json:"logLevel"`
+	Charset   Charset   `yaml:"charset" json:"charset"`
+}
+
+func TestEnumYAML(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    enumFields
+		wantErr string
+	}{
+		{in: "format: esm", want: enumFields{Format: Format(api.FormatESModule)}},
+		{in: "format: ESM", want: enumFields{Format: Format(api.FormatESModule)}},
+		{in: "format: commonjs", want: enumFields{Format: Format(api.FormatCommonJS)}},
+		{in: "format: 3", want: enumFields{Format: Format(api.FormatESModule)}},
+		{in: "format: 0", want: enumFields{Format: Format(api.FormatDefault)}},
+		{in: "platform: node", want: enumFields{Platform: Platform(api.PlatformNode)}},
+		{in: "platform: 2", want: enumFields{Platform: Platform(api.PlatformNode)}},
+		{in: "sourcemap: external", want: enumFields{Sourcemap: SourceMap(api.SourceMapExternal)}},
+		{in: "sourcemap: 3", want: enumFields{Sourcemap: SourceMap(api.SourceMapExternal)}},
+		{in: "sourcemap: true", want: enumFields{Sourcemap: SourceMap(api.SourceMapLinked)}},
+		{in: "sourcemap: false", want: enumFields{Sourcemap: SourceMap(api.SourceMapNone)}},
+		{in: "logLevel: warning", want: enumFields{LogLevel: LogLevel(api.LogLevelWarning)}},
+		{in: "logLevel: 4", want: enumFields{LogLevel: LogLevel(api.LogLevelWarning)}},
+		{in: "charset: utf8", want: enumFields{Charset: Charset(api.CharsetUTF8)}},
+		{in: "format: es6", wantErr: `line 1: unknown format value "es6", expected one of: cjs, commonjs, default, esm, iife`},
+		{in: "format: 9", wantErr: "line 1: unknown format value 9"},
+		// Only the enums older configs used as integers accept them.
+		{in: "charset: 2", wantErr: `unknown charset value "2"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got := enumFields{}
+			err := yaml.Unmarshal([]byte(tt.in), &got)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Unmarshal(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unmarshal(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnumJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    enumFields
+		wantErr string
+	}{
+		{in: `{"format": "esm"}`, want: enumFields{Format: Format(api.FormatESModule)}},
+		{in: `{"format": 3}`, want: enumFields{Format: Format(api.FormatESModule)}},
+		{in: `{"platform": 2}`, want: enumFields{Platform: Platform(api.PlatformNode)}},
+		{in: `{"sourcemap": true}`, want: enumFields{Sourcemap: SourceMap(api.SourceMapLinked)}},
+		{in: `{"format": "es6"}`, wantErr: `unknown format value "es6"`},
+		{in: `{"format": 9}`, wantErr: "unknown format value 9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got := enumFields{}
+			err := json.Unmarshal([]byte(tt.in), &got)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Unmarshal(%s) error = %v, want %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unmarshal(%s) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
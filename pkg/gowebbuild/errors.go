@@ -0,0 +1,74 @@
+package gowebbuild
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// ErrUnknownStage is returned by RunStage for stages that don't exist.
+var ErrUnknownStage = errors.New("unknown stage")
+
+// StageError reports the failed stage of a setup.
+type StageError struct {
+	Setup string
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("setup %s: %s: %v", e.Setup, e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// BuildError holds the errors that esbuild reported.
+type BuildError struct {
+	Errors   []api.Message
+	Warnings []api.Message
+}
+
+func (e *BuildError) Error() string {
+	lines := []string{fmt.Sprintf("esbuild failed with %d error(s)", len(e.Errors))}
+	for _, msg := range e.Errors {
+		lines = append(lines, FormatMessage(msg))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// FormatMessage formats an esbuild message as file:line:column: text.
+func FormatMessage(msg api.Message) string {
+	if msg.Location == nil {
+		return msg.Text
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", msg.Location.File, msg.Location.Line, msg.Location.Column, msg.Text)
+}
+
+// WarningsError is returned in strict mode for stages that reported warnings.
+type WarningsError struct {
+	Count int
+}
+
+func (e *WarningsError) Error() string {
+	return fmt.Sprintf("%d warning(s) in strict mode", e.Count)
+}
+
+// CommandError is returned when the post build command fails.
+type CommandError struct {
+	Command string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command `%s` failed: %v", e.Command, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,215 @@
+package gowebbuild
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CycleError is returned when setups depend on each other through dependsOn.
+type CycleError struct {
+	// Cycle lists the setup names of the cycle, starting and ending with the same setup.
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// Result is the outcome of a pipeline run by RunGraph.
+type Result struct {
+	Setup    string
+	Duration time.Duration
+	Err      error
+	// Skipped is set for pipelines that didn't run, because a dependency failed or another pipeline failed first.
+	Skipped bool
+}
+
+type graphConfig struct {
+	jobs      int
+	keepGoing bool
+}
+
+// GraphOption configures RunGraph.
+type GraphOption func(*graphConfig)
+
+// WithJobs limits how many pipelines RunGraph runs at the same time. There is no limit if jobs is less than 1.
+func WithJobs(jobs int) GraphOption {
+	return func(c *graphConfig) {
+		c.jobs = jobs
+	}
+}
+
+// WithKeepGoing keeps starting pipelines after a failure. Only the pipelines that depend on a failed one are skipped.
+func WithKeepGoing() GraphOption {
+	return func(c *graphConfig) {
+		c.keepGoing = true
+	}
+}
+
+// RunGraph runs the pipelines concurrently. A pipeline starts once all pipelines named in its Options.DependsOn
+// finished successfully. Dependencies that aren't part of pipelines (like setups left out with --only) are ignored.
+// After the first failure no more pipelines are started (unless WithKeepGoing is used) and the running ones are finished.
+//
+// The results are in the order of pipelines. The returned error is the first failure, or a *CycleError if
+// the pipelines depend on each other, in which case none of them runs.
+func RunGraph(ctx context.Context, pipelines []*Pipeline, options ...GraphOption) ([]Result, error) {
+	c := &graphConfig{}
+	for _, option := range options {
+		option(c)
+	}
+
+	deps, err := pipelineDeps(pipelines)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, len(pipelines))
+	for i, p := range pipelines {
+		results[i] = Result{Setup: p.Name, Skipped: true}
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		started  = make([]bool, len(pipelines))
+		finished = make([]bool, len(pipelines))
+		done     = make(chan int)
+		running  = 0
+	)
+
+	// ready reports whether all dependencies of pipeline i finished successfully.
+	ready := func(i int) bool {
+		for _, d := range deps[i] {
+			if !finished[d] || results[d].Err != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	for {
+		mu.Lock()
+		stop := (firstErr != nil && !c.keepGoing) || ctx.Err() != nil
+
+		for i, p := range pipelines {
+			if stop || started[i] || !ready(i) || (c.jobs > 0 && running >= c.jobs) {
+				continue
+			}
+
+			started[i] = true
+			running++
+
+			go func(i int, p *Pipeline) {
+				start := time.Now()
+				err := p.Run(ctx)
+
+				mu.Lock()
+				results[i] = Result{Setup: p.Name, Duration: time.Since(start), Err: err}
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+
+				done <- i
+			}(i, p)
+		}
+
+		idle := running == 0
+		mu.Unlock()
+
+		if idle {
+			break
+		}
+
+		i := <-done
+		mu.Lock()
+		finished[i] = true
+		running--
+		mu.Unlock()
+	}
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+
+	return results, firstErr
+}
+
+// pipelineDeps returns the indexes of the pipelines each pipeline depends on.
+func pipelineDeps(pipelines []*Pipeline) ([][]int, error) {
+	index := map[string]int{}
+	for i, p := range pipelines {
+		if p.Options.Name != "" {
+			index[p.Options.Name] = i
+		}
+	}
+
+	deps := make([][]int, len(pipelines))
+	for i, p := range pipelines {
+		for _, name := range p.Options.DependsOn {
+			if d, ok := index[name]; ok {
+				deps[i] = append(deps[i], d)
+			}
+		}
+	}
+
+	if cycle := findCycle(deps); cycle != nil {
+		names := []string{}
+		for _, i := range cycle {
+			names = append(names, pipelines[i].Name)
+		}
+		return nil, &CycleError{Cycle: names}
+	}
+
+	return deps, nil
+}
+
+// findCycle returns the nodes of a cycle in the graph (with the first node repeated at the end), or nil if there is none.
+func findCycle(deps [][]int) []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(deps))
+	stack := []int{}
+
+	var visit func(i int) []int
+	visit = func(i int) []int {
+		state[i] = visiting
+		stack = append(stack, i)
+
+		for _, d := range deps[i] {
+			switch state[d] {
+			case visiting:
+				for j, n := range stack {
+					if n == d {
+						return append(append([]int{}, stack[j:]...), d)
+					}
+				}
+			case unvisited:
+				if cycle := visit(d); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+		return nil
+	}
+
+	for i := range deps {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package gowebbuild
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name string
+		deps [][]int
+		want []int
+	}{
+		{name: "empty", deps: [][]int{}, want: nil},
+		{name: "independent", deps: [][]int{{}, {}, {}}, want: nil},
+		{name: "chain", deps: [][]int{{}, {0}, {1}}, want: nil},
+		{name: "diamond", deps: [][]int{{}, {0}, {0}, {1, 2}}, want: nil},
+		{name: "self", deps: [][]int{{0}}, want: []int{0, 0}},
+		{name: "pair", deps: [][]int{{1}, {0}}, want: []int{0, 1, 0}},
+		{name: "behind a chain", deps: [][]int{{1}, {2}, {3}, {2}}, want: []int{2, 3, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findCycle(tt.deps); !slices.Equal(got, tt.want) {
+				t.Errorf("findCycle(%v) = %v, want %v", tt.deps, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunGraph(t *testing.T) {
+	type setup struct {
+		name      string
+		dependsOn []string
+		fail      bool
+	}
+
+	tests := []struct {
+		name    string
+		setups  []setup
+		options []GraphOption
+		// ran lists the setups in the order they started.
+		ran     []string
+		skipped []string
+		wantErr func(error) bool
+	}{
+		{
+			name:   "dependencies first",
+			setups: []setup{{name: "app", dependsOn: []string{"lib"}}, {name: "lib", dependsOn: []string{"base"}}, {name: "base"}},
+			ran:    []string{"base", "lib", "app"},
+		},
+		{
+			name:   "unknown dependency is ignored",
+			setups: []setup{{name: "app", dependsOn: []string{"skipped"}}},
+			ran:    []string{"app"},
+		},
+		{
+			name:    "failed dependency",
+			setups:  []setup{{name: "lib", fail: true}, {name: "app", dependsOn: []string{"lib"}}},
+			ran:     []string{"lib"},
+			skipped: []string{"app"},
+			wantErr: func(err error) bool { return errors.Is(err, ErrUnknownStage) },
+		},
+		{
+			name:    "stop after failure",
+			setups:  []setup{{name: "a", fail: true}, {name: "b"}},
+			options: []GraphOption{WithJobs(1)},
+			ran:     []string{"a"},
+			skipped: []string{"b"},
+			wantErr: func(err error) bool { return errors.Is(err, ErrUnknownStage) },
+		},
+		{
+			name:    "keep going",
+			setups:  []setup{{name: "a", fail: true}, {name: "b"}, {name: "c", dependsOn: []string{"a"}}},
+			options: []GraphOption{WithJobs(1), WithKeepGoing()},
+			ran:     []string{"a", "b"},
+			skipped: []string{"c"},
+			wantErr: func(err error) bool { return errors.Is(err, ErrUnknownStage) },
+		},
+		{
+			name:    "cycle",
+			setups:  []setup{{name: "a", dependsOn: []string{"b"}}, {name: "b", dependsOn: []string{"a"}}},
+			wantErr: func(err error) bool { return errors.As(err, new(*CycleError)) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			ran := []string{}
+			record := func(ev Event) {
+				if ev.Type == EventStageStarted {
+					mu.Lock()
+					ran = append(ran, ev.Setup)
+					mu.Unlock()
+				}
+			}
+
+			pipelines := []*Pipeline{}
+			for _, s := range tt.setups {
+				// The replace stage does nothing without replace rules, an unknown stage fails.
+				stage := StageReplace
+				if s.fail {
+					stage = Stage("fail")
+				}
+
+				opts := Options{Name: s.name, DependsOn: s.dependsOn}
+				pipelines = append(pipelines, New(opts, WithStages(stage), WithEventHandler(record)))
+			}
+
+			results, err := RunGraph(context.Background(), pipelines, tt.options...)
+
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("RunGraph() error = %v", err)
+			}
+			if tt.wantErr != nil && (err == nil || !tt.wantErr(err)) {
+				t.Fatalf("RunGraph() error = %v, not the expected error", err)
+			}
+
+			if !slices.Equal(ran, tt.ran) {
+				t.Errorf("ran %v, want %v", ran, tt.ran)
+			}
+
+			skipped := []string{}
+			for _, r := range results {
+				if r.Skipped {
+					skipped = append(skipped, r.Setup)
+				}
+			}
+			if !slices.Equal(skipped, tt.skipped) {
+				t.Errorf("skipped %v, want %v", skipped, tt.skipped)
+			}
+		})
+	}
+}
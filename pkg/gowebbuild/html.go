@@ -0,0 +1,249 @@
+package gowebbuild
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/trading-peter/gowebbuild/fsutils"
+)
+
+// htmlPage is an HTML entry point. Its module scripts and stylesheets are bundled by esbuild
+// and the page is written to Out with references to the output files.
+type htmlPage struct {
+	Path string
+	Out  string
+	doc  *goquery.Document
+	refs []htmlRef
+}
+
+// htmlRef is a script or stylesheet reference of a page.
+type htmlRef struct {
+	sel  *goquery.Selection
+	attr string
+	// entry is the absolute path of the referenced file, an entry point of the build.
+	entry string
+	// absolute is set for references like /src/main.js, they are replaced with absolute references as well.
+	absolute bool
+	query    string
+}
+
+// isHTMLEntryPoint reports whether an entry point is an HTML page.
+func isHTMLEntryPoint(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".html" || ext == ".htm"
+}
+
+// htmlPages replaces the HTML pages in the entry points of the build with the local module scripts
+// and stylesheets they reference. Patterns like ./src/*.html are expanded.
+func (p *Pipeline) htmlPages(buildOptions *api.BuildOptions) ([]*htmlPage, error) {
+	entries := []string{}
+	files := []string{}
+
+	for _, entry := range buildOptions.EntryPoints {
+		if !isHTMLEntryPoint(entry) {
+			entries = append(entries, entry)
+			continue
+		}
+
+		matches, err := filepath.Glob(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry point %s: %w", entry, err)
+		}
+		files = append(files, matches...)
+	}
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	outbase := p.Options.ESBuild.Outbase
+	if outbase == "" {
+		outbase = commonDir(files)
+	}
+
+	pages := []*htmlPage{}
+	for _, file := range files {
+		page, err := p.parsePage(file)
+		if err != nil {
+			return nil, err
+		}
+
+		rel, err := filepath.Rel(outbase, file)
+		if err != nil {
+			return nil, err
+		}
+		page.Out = filepath.Join(p.Options.ESBuild.Outdir, rel)
+
+		for _, ref := range page.refs {
+			if !slices.Contains(entries, ref.entry) {
+				entries = append(entries, ref.entry)
+			}
+		}
+
+		pages = append(pages, page)
+	}
+
+	buildOptions.EntryPoints = entries
+	return pages, nil
+}
+
+// parsePage reads an HTML page and finds the references to local module scripts and stylesheets.
+// Other scripts, like the ones loaded from a CDN or copied into the output folder, are left alone.
+func (p *Pipeline) parsePage(file string) (*htmlPage, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	page := &htmlPage{Path: file, doc: doc}
+
+	add := func(s *goquery.Selection, attr string) {
+		u, err := url.Parse(s.AttrOr(attr, ""))
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+			return
+		}
+
+		ref := htmlRef{sel: s, attr: attr, absolute: strings.HasPrefix(u.Path, "/"), query: u.RawQuery}
+
+		// Absolute references are resolved from the working directory (the folder of the config file),
+		// relative ones from the folder of the page.
+		if ref.absolute {
+			ref.entry = p.absPath(strings.TrimPrefix(filepath.FromSlash(u.Path), string(filepath.Separator)))
+		} else {
+			ref.entry = filepath.Join(filepath.Dir(file), filepath.FromSlash(u.Path))
+		}
+
+		if fsutils.IsFile(ref.entry) {
+			page.refs = append(page.refs, ref)
+		}
+	}
+
+	doc.Find(`script[type="module"][src]`).Each(func(i int, s *goquery.Selection) { add(s, "src") })
+	doc.Find(`link[rel="stylesheet"][href]`).Each(func(i int, s *goquery.Selection) { add(s, "href") })
+
+	return page, nil
+}
+
+// writePages points the references of the pages at the output files of the last esbuild run and writes them
+// to the output folder. Script entry points that import CSS get a stylesheet link for their CSS bundle.
+func (p *Pipeline) writePages(pages []*htmlPage, result *api.BuildResult) error {
+	meta := parseMetafile(result.Metafile)
+	if meta == nil {
+		return fmt.Errorf("no metafile to find the output files of the HTML entry points")
+	}
+
+	type entryOutput struct {
+		file, cssBundle string
+	}
+
+	outputs := map[string]entryOutput{}
+	for out, o := range meta.Outputs {
+		if o.EntryPoint == "" || strings.HasSuffix(out, ".map") {
+			continue
+		}
+
+		output := entryOutput{file: p.absPath(out)}
+		if o.CSSBundle != "" {
+			output.cssBundle = p.absPath(o.CSSBundle)
+		}
+		outputs[p.absPath(o.EntryPoint)] = output
+	}
+
+	for _, page := range pages {
+		head := page.doc.Find("head")
+
+		for _, ref := range page.refs {
+			output, ok := outputs[ref.entry]
+			if !ok {
+				p.warn(StageESBuild, fmt.Sprintf("No output file for %s referenced in %s", ref.entry, page.Path), nil)
+				continue
+			}
+
+			ref.sel.SetAttr(ref.attr, p.pageRef(page, output.file, ref))
+
+			if output.cssBundle != "" {
+				head.AppendHtml(fmt.Sprintf(`<link rel="stylesheet" href="%s">`, html.EscapeString(p.pageRef(page, output.cssBundle, ref))))
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := goquery.Render(&buf, page.doc.Selection); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(page.Out), 0755); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(page.Out, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+
+		p.emit(Event{Type: EventFileWritten, Stage: StageESBuild, Path: page.Out, Message: fmt.Sprintf("Wrote page %s", page.Out)})
+	}
+
+	return nil
+}
+
+// pageRef returns the reference from a page to an output file: relative to the page, or relative to the output folder
+// (below esbuild.publicPath if it's set) for absolute references.
+func (p *Pipeline) pageRef(page *htmlPage, file string, ref htmlRef) string {
+	var target string
+
+	if ref.absolute {
+		rel, _ := filepath.Rel(p.Options.ESBuild.Outdir, file)
+		target = strings.TrimSuffix(p.Options.ESBuild.PublicPath, "/") + "/" + filepath.ToSlash(rel)
+	} else {
+		rel, _ := filepath.Rel(filepath.Dir(page.Out), file)
+		target = filepath.ToSlash(rel)
+	}
+
+	if ref.query != "" {
+		target += "?" + ref.query
+	}
+
+	return target
+}
+
+// pageEntryPoints returns the scripts and stylesheets of an HTML entry point of the last build.
+func (p *Pipeline) pageEntryPoints(path string) []string {
+	entries := []string{}
+
+	for _, page := range p.pages {
+		if page.Path != path {
+			continue
+		}
+
+		for _, ref := range page.refs {
+			entries = append(entries, ref.entry)
+		}
+	}
+
+	return entries
+}
+
+// commonDir returns the deepest folder that contains all files.
+func commonDir(files []string) string {
+	dir := filepath.Dir(files[0])
+
+	for _, file := range files[1:] {
+		for !strings.HasPrefix(file, dir+string(filepath.Separator)) && dir != filepath.Dir(dir) {
+			dir = filepath.Dir(dir)
+		}
+	}
+
+	return dir
+}
@@ -0,0 +1,82 @@
+package gowebbuild
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// WithIncremental makes the esbuild stage keep an esbuild context between runs and rebuild with it,
+// which reuses esbuild's caches and only re-parses changed files. The pipeline has to be closed when it's not needed anymore.
+func WithIncremental() PipelineOption {
+	return func(p *Pipeline) {
+		p.incremental = true
+	}
+}
+
+// rebuild builds with the esbuild context of the pipeline. The context is created by the first build and again when the entry points
+// change, as the build options of a context are fixed (HTML entry points can reference other scripts after an edit).
+// The build is canceled when ctx is done.
+func (p *Pipeline) rebuild(ctx context.Context, buildOptions api.BuildOptions) api.BuildResult {
+	p.ctxMu.Lock()
+	defer p.ctxMu.Unlock()
+
+	if p.esbuildCtx != nil && !slices.Equal(p.ctxEntryPoints, buildOptions.EntryPoints) {
+		p.info(StageESBuild, "Entry points changed, starting a new esbuild context")
+		p.esbuildCtx.Dispose()
+		p.esbuildCtx = nil
+	}
+
+	initial := p.esbuildCtx == nil
+	if initial {
+		ctx, err := api.Context(buildOptions)
+		if err != nil {
+			return api.BuildResult{Errors: err.Errors}
+		}
+
+		p.esbuildCtx = ctx
+		p.ctxEntryPoints = buildOptions.EntryPoints
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func(esbuildCtx api.BuildContext) {
+		select {
+		case <-ctx.Done():
+			esbuildCtx.Cancel()
+		case <-done:
+		}
+	}(p.esbuildCtx)
+
+	start := time.Now()
+	result := p.esbuildCtx.Rebuild()
+
+	if ctx.Err() != nil {
+		p.info(StageESBuild, fmt.Sprintf("Build canceled after %s", time.Since(start).Round(time.Millisecond)))
+		return result
+	}
+
+	if initial {
+		p.info(StageESBuild, fmt.Sprintf("Initial build in %s", time.Since(start).Round(time.Millisecond)))
+	} else {
+		p.info(StageESBuild, fmt.Sprintf("Incremental rebuild in %s", time.Since(start).Round(time.Millisecond)))
+	}
+
+	return result
+}
+
+// Close disposes the esbuild context of an incremental pipeline. It waits for a running build to finish.
+// The pipeline can be used again afterwards, the next build creates a new context.
+func (p *Pipeline) Close() {
+	p.ctxMu.Lock()
+	defer p.ctxMu.Unlock()
+
+	if p.esbuildCtx != nil {
+		p.esbuildCtx.Dispose()
+		p.esbuildCtx = nil
+	}
+}
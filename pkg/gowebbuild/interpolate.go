@@ -0,0 +1,339 @@
+package gowebbuild
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+// cfgVars resolves the variables used in config values. Lookup order: the environment of the process,
+// .env.<profile>, .env and the build variables.
+type cfgVars struct {
+	dotenv    map[string]string
+	files     []string
+	build     map[string]func() (string, bool)
+	buildVals map[string]*string
+	// unset collects the variables that were referenced without a default but aren't set.
+	unset []string
+}
+
+// loadCfgVars reads the .env and .env.<profile> files next to the config (if they exist).
+func loadCfgVars(dir, profile string) (*cfgVars, error) {
+	v := &cfgVars{
+		dotenv:    map[string]string{},
+		buildVals: map[string]*string{},
+	}
+
+	envFiles := []string{filepath.Join(dir, ".env")}
+	if profile != "" {
+		envFiles = append(envFiles, filepath.Join(dir, ".env."+profile))
+	}
+
+	for _, file := range envFiles {
+		vars, err := readDotEnv(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for k, val := range vars {
+			v.dotenv[k] = val
+		}
+		v.files = append(v.files, file)
+	}
+
+	git := func(args ...string) func() (string, bool) {
+		return func() (string, bool) {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+			out, err := cmd.Output()
+			if err != nil {
+				return "", false
+			}
+			return strings.TrimSpace(string(out)), true
+		}
+	}
+
+	v.build = map[string]func() (string, bool){
+		"GIT_COMMIT":       git("rev-parse", "HEAD"),
+		"GIT_SHORT_COMMIT": git("rev-parse", "--short", "HEAD"),
+		"GIT_BRANCH":       git("rev-parse", "--abbrev-ref", "HEAD"),
+		"VERSION":          git("describe", "--tags", "--always", "--dirty"),
+		"PACKAGE_VERSION": func() (string, bool) {
+			data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+			if err != nil {
+				return "", false
+			}
+			version := gjson.GetBytes(data, "version")
+			return version.String(), version.Exists()
+		},
+		"BUILD_TIME": func() (string, bool) {
+			return time.Now().UTC().Format(time.RFC3339), true
+		},
+	}
+
+	return v, nil
+}
+
+func (v *cfgVars) lookup(name string) (string, bool) {
+	if val, ok := os.LookupEnv(name); ok {
+		return val, true
+	}
+
+	if val, ok := v.dotenv[name]; ok {
+		return val, true
+	}
+
+	// Build variables are only computed when used, and only once, so all fields see the same value.
+	if val, ok := v.buildVals[name]; ok {
+		return *val, val != nil
+	}
+
+	if fn, ok := v.build[name]; ok {
+		val, ok := fn()
+		if !ok {
+			v.buildVals[name] = nil
+			return "", false
+		}
+		v.buildVals[name] = &val
+		return val, true
+	}
+
+	return "", false
+}
+
+// expand replaces ${VAR}, ${VAR:-default}, ${VAR:?error} and $VAR in s. $$ is a literal $.
+// $VAR is only replaced if VAR is set, so code like $el in a banner stays as it is.
+func (v *cfgVars) expand(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+
+		case next == '{':
+			end := matchingBrace(s, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference %q", s[i:])
+			}
+
+			val, err := v.expandRef(s[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+
+			b.WriteString(val)
+			i = end
+
+		case isVarStart(next):
+			end := i + 1
+			for end < len(s) && isVarChar(s[end]) {
+				end++
+			}
+
+			if val, ok := v.lookup(s[i+1 : end]); ok {
+				b.WriteString(val)
+			} else {
+				b.WriteString(s[i:end])
+			}
+			i = end - 1
+
+		default:
+			b.WriteByte('$')
+		}
+	}
+
+	return b.String(), nil
+}
+
+// expandRef resolves the content of a ${...} reference.
+func (v *cfgVars) expandRef(ref string) (string, error) {
+	end := 0
+	for end < len(ref) && (isVarChar(ref[end]) && (end > 0 || isVarStart(ref[end]))) {
+		end++
+	}
+
+	name, op := ref[:end], ref[end:]
+	if name == "" {
+		return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+	}
+
+	val, ok := v.lookup(name)
+
+	switch {
+	case op == "":
+		if !ok {
+			v.unset = append(v.unset, name)
+		}
+		return val, nil
+
+	case strings.HasPrefix(op, ":-"):
+		if !ok || val == "" {
+			return v.expand(op[2:])
+		}
+		return val, nil
+
+	case strings.HasPrefix(op, ":?"):
+		if !ok || val == "" {
+			msg, err := v.expand(op[2:])
+			if err != nil {
+				return "", err
+			}
+			if msg == "" {
+				return "", fmt.Errorf("variable %s is required", name)
+			}
+			return "", fmt.Errorf("variable %s: %s", name, msg)
+		}
+		return val, nil
+	}
+
+	return "", fmt.Errorf("invalid variable reference ${%s}, expected ${%s}, ${%s:-default} or ${%s:?error}", ref, name, name, name)
+}
+
+// matchingBrace returns the index of the } closing the { at start, allowing nested references in defaults.
+func matchingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func isVarStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isVarChar(c byte) bool {
+	return isVarStart(c) || (c >= '0' && c <= '9')
+}
+
+// interpolate expands the variables in all scalar values of a config file. Only the selected profile is expanded,
+// so required variables of other profiles don't have to be set.
+func (d *cfgDoc) interpolate(node *yaml.Node, path string, errs *ConfigErrors) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		unset := len(d.vars.unset)
+		val, err := d.vars.expand(node.Value)
+		if err != nil {
+			*errs = append(*errs, ConfigError{File: d.fileOf(node), Line: node.Line, Column: node.Column, Path: path, Msg: err.Error()})
+			return
+		}
+
+		// A typo in a variable name would silently turn into an empty value.
+		for _, name := range d.vars.unset[unset:] {
+			msg := fmt.Sprintf("variable %s is not set and expands to an empty string, use ${%s:-} if that's intended", name, name)
+			d.warnings = append(d.warnings, ConfigError{File: d.fileOf(node), Line: node.Line, Column: node.Column, Path: path, Msg: msg})
+		}
+
+		if val != node.Value {
+			node.Value = val
+
+			// Let yaml resolve the type of the expanded value again, so `port: ${PORT}` decodes into an int.
+			if node.Style&yaml.TaggedStyle == 0 {
+				node.Tag = ""
+				node.Style = 0
+			}
+		}
+
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			d.interpolate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content)-1; i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+
+			if key == "profiles" && value.Kind == yaml.MappingNode {
+				if profile := mappingValue(value, d.profile); profile != nil {
+					d.interpolate(profile, joinCfgPath(path, "profiles."+d.profile), errs)
+				}
+				continue
+			}
+
+			d.interpolate(value, joinCfgPath(path, key), errs)
+		}
+	}
+}
+
+// readDotEnv parses a .env file with KEY=value lines. Values can be single quoted (taken literally)
+// or double quoted (supporting \n, \t, \" and \\ escapes). Lines starting with # and an optional export prefix are ignored.
+func readDotEnv(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNr := 0
+
+	for scanner.Scan() {
+		lineNr++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", path, lineNr)
+		}
+
+		value = strings.TrimSpace(value)
+
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value, err = strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid quoted value for %s", path, lineNr, key)
+			}
+
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		vars[key] = value
+	}
+
+	return vars, scanner.Err()
+}
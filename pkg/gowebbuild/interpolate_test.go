@@ -0,0 +1,215 @@
+package gowebbuild
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("GWB_TEST_ENV", "env")
+	t.Setenv("GWB_TEST_EMPTY", "")
+
+	v := &cfgVars{
+		dotenv:    map[string]string{"GWB_TEST_DOTENV": "dotenv", "GWB_TEST_ENV": "shadowed"},
+		buildVals: map[string]*string{},
+		build: map[string]func() (string, bool){
+			"GWB_TEST_BUILD":   func() (string, bool) { return "build", true },
+			"GWB_TEST_MISSING": func() (string, bool) { return "", false },
+		},
+	}
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{in: "no variables", want: "no variables"},
+		{in: "${GWB_TEST_ENV}", want: "env"},
+		{in: "$GWB_TEST_ENV/dist", want: "env/dist"},
+		{in: "${GWB_TEST_DOTENV}-${GWB_TEST_BUILD}", want: "dotenv-build"},
+		{in: "${GWB_TEST_UNSET}", want: ""},
+		{in: "$el.focus()", want: "$el.focus()"},
+		{in: "costs $$5", want: "costs $5"},
+		{in: "trailing $", want: "trailing $"},
+		{in: "${GWB_TEST_UNSET:-fallback}", want: "fallback"},
+		{in: "${GWB_TEST_EMPTY:-fallback}", want: "fallback"},
+		{in: "${GWB_TEST_ENV:-fallback}", want: "env"},
+		{in: "${GWB_TEST_UNSET:-${GWB_TEST_DOTENV}}", want: "dotenv"},
+		{in: "${GWB_TEST_ENV:?is required}", want: "env"},
+		{in: "${GWB_TEST_UNSET:?set it in .env}", wantErr: "variable GWB_TEST_UNSET: set it in .env"},
+		{in: "${GWB_TEST_MISSING:?}", wantErr: "variable GWB_TEST_MISSING is required"},
+		{in: "${GWB_TEST_ENV", wantErr: "unterminated variable reference"},
+		{in: "${}", wantErr: "invalid variable reference ${}"},
+		{in: "${GWB_TEST_ENV:+x}", wantErr: "expected ${GWB_TEST_ENV}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := v.expand(tt.in)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expand(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expand(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("expand(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadDotEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "plain",
+			content: "PORT=8080\nHOST = localhost \n",
+			want:    map[string]string{"PORT": "8080", "HOST": "localhost"},
+		},
+		{
+			name:    "comments and export",
+			content: "# comment\n\nexport API=https://example.com # trailing\n",
+			want:    map[string]string{"API": "https://example.com"},
+		},
+		{
+			name:    "single quotes are literal",
+			content: `TOKEN='a\nb # c'`,
+			want:    map[string]string{"TOKEN": `a\nb # c`},
+		},
+		{
+			name:    "double quotes support escapes",
+			content: `MSG="line\n\"quoted\""`,
+			want:    map[string]string{"MSG": "line\n\"quoted\""},
+		},
+		{
+			name:    "empty value",
+			content: "EMPTY=",
+			want:    map[string]string{"EMPTY": ""},
+		},
+		{
+			name:    "missing equals sign",
+			content: "PORT=8080\nBROKEN\n",
+			wantErr: ":2: expected KEY=value",
+		},
+		{
+			name:    "invalid quoted value",
+			content: `BAD="\q"`,
+			wantErr: ":1: invalid quoted value for BAD",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readDotEnv(path)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readDotEnv() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("readDotEnv() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("readDotEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigVariables(t *testing.T) {
+	tests := []struct {
+		cfg   string
+		env   string
+		field func(Options) any
+		want  any
+	}{
+		{cfg: "esbuild:\n  format: ${GWB_TEST_VALUE}", env: "esm", field: func(o Options) any { return o.ESBuild.Format }, want: Format(api.FormatESModule)},
+		{cfg: "esbuild:\n  format: ${GWB_TEST_VALUE}", env: "3", field: func(o Options) any { return o.ESBuild.Format }, want: Format(api.FormatESModule)},
+		{cfg: "esbuild:\n  format: \"${GWB_TEST_VALUE}\"", env: "3", field: func(o Options) any { return o.ESBuild.Format }, want: Format(api.FormatESModule)},
+		{cfg: "esbuild:\n  sourcemap: ${GWB_TEST_VALUE}", env: "true", field: func(o Options) any { return o.ESBuild.Sourcemap }, want: SourceMap(api.SourceMapLinked)},
+		{cfg: "esbuild:\n  sourcemap: ${GWB_TEST_VALUE}", env: "external", field: func(o Options) any { return o.ESBuild.Sourcemap }, want: SourceMap(api.SourceMapExternal)},
+		{cfg: "esbuild:\n  target: ${GWB_TEST_VALUE}", env: "10", field: func(o Options) any { return o.ESBuild.Target.Target }, want: api.Target(10)},
+		{cfg: "esbuild:\n  minify: ${GWB_TEST_VALUE}", env: "true", field: func(o Options) any { return o.ESBuild.Minify }, want: true},
+		{cfg: "serve:\n  port: ${GWB_TEST_VALUE}", env: "3000", field: func(o Options) any { return o.Serve.Port }, want: 3000},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.ReplaceAll(tt.cfg, "\n", " ")+" "+tt.env, func(t *testing.T) {
+			t.Setenv("GWB_TEST_VALUE", tt.env)
+
+			path := filepath.Join(t.TempDir(), "gowebbuild.yaml")
+			if err := os.WriteFile(path, []byte(tt.cfg), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			if got := tt.field(cfg.Setups[0]); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigUnsetVariables(t *testing.T) {
+	tests := []struct {
+		cfg  string
+		want []string
+	}{
+		{cfg: "esbuild:\n  outdir: ${GWB_TEST_UNSET}/dist", want: []string{"gowebbuild.yaml:2:11: esbuild.outdir: variable GWB_TEST_UNSET is not set"}},
+		{cfg: "esbuild:\n  outdir: ${GWB_TEST_UNSET:-}dist", want: []string{}},
+		{cfg: "esbuild:\n  outdir: ${GWB_TEST_EMPTY}dist", want: []string{}},
+		{cfg: "esbuild:\n  outdir: $GWB_TEST_UNSET", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.ReplaceAll(tt.cfg, "\n", " "), func(t *testing.T) {
+			t.Setenv("GWB_TEST_EMPTY", "")
+
+			path := filepath.Join(t.TempDir(), "gowebbuild.yaml")
+			if err := os.WriteFile(path, []byte(tt.cfg), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			if len(cfg.Warnings) != len(tt.want) {
+				t.Fatalf("warnings = %v, want %q", cfg.Warnings, tt.want)
+			}
+			for i, w := range cfg.Warnings {
+				if !strings.Contains(w.Error(), tt.want[i]) {
+					t.Errorf("warning = %q, want %q", w.Error(), tt.want[i])
+				}
+			}
+		})
+	}
+}
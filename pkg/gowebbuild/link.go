@@ -0,0 +1,148 @@
+package gowebbuild
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/otiai10/copy"
+	"github.com/tidwall/gjson"
+	"github.com/trading-peter/gowebbuild/fsutils"
+	"github.com/trading-peter/gowebbuild/internal/fswatch"
+)
+
+// Link watches the npm packages found in Options.Link.From that are dependencies of the package in Options.Link.To.
+// Changes are copied into the node_modules of Options.Link.To and a build is requested on the returned channel after each copy.
+// Watching stops when ctx is done.
+func (p *Pipeline) Link(ctx context.Context) (<-chan struct{}, error) {
+	from, to := p.Options.Link.From, p.Options.Link.To
+	requestBuildCh := make(chan struct{})
+
+	// Load package.json in destination.
+	destPkg, err := readFileContent(filepath.Join(to, "package.json"))
+	if err != nil {
+		return nil, err
+	}
+	depsRaw := gjson.Get(destPkg, "dependencies").Map()
+	deps := map[string]bool{}
+	for k := range depsRaw {
+		deps[k] = true
+	}
+
+	packages := map[string]string{}
+	packageFiles := fsutils.FindFiles(from, "package.json")
+
+	for i := range packageFiles {
+		content, err := readFileContent(packageFiles[i])
+		if err != nil {
+			p.warn(StageLink, fmt.Sprintf("Failed to read %s: %v", packageFiles[i], err), err)
+			continue
+		}
+		name := gjson.Get(content, "name").String()
+
+		if deps[name] {
+			pp, err := filepath.Abs(filepath.Dir(packageFiles[i]))
+			if err == nil {
+				packages[name] = pp
+			}
+		}
+	}
+
+	p.info(StageLink, fmt.Sprintf("Found %d npm packages to monitor for changes.", len(packages)))
+
+	w, err := fswatch.New(fswatch.Options{
+		Backend: fswatch.Backend(p.Options.Watch.Backend),
+		// Only watch JavaScript and TypeScript files, outside of node_modules and hidden folders.
+		Skip: func(path string, dir bool) bool {
+			if isExcludedPath(path, "node_modules", ".git") || strings.HasPrefix(filepath.Base(path), ".") {
+				return true
+			}
+			return !dir && !isIncludedExt(filepath.Base(path), "*.js", "*.ts")
+		},
+		Log: func(msg string) { p.info(StageLink, msg) },
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Instead of watching the entire directory tree, only watch the package directories
+	// that we need to monitor
+	for _, packagePath := range packages {
+		if err := w.Add(packagePath); err != nil {
+			p.warn(StageLink, fmt.Sprintf("Error setting up watcher for %s: %v", packagePath, err), err)
+		}
+	}
+
+	go func() {
+		defer w.Close()
+
+		for {
+			select {
+			case event := <-w.Events():
+				p.info(StageLink, fmt.Sprintf("File %s changed", event.Path))
+				for k, v := range packages {
+					if strings.HasPrefix(event.Path, v) {
+						src := filepath.Dir(event.Path)
+						subPath, _ := filepath.Rel(v, src)
+						dest := filepath.Join(to, "node_modules", k, subPath)
+						p.emit(Event{Type: EventFileCopied, Stage: StageLink, Src: src, Dest: dest, Message: fmt.Sprintf("Copying %s to %s", src, dest)})
+						err := copy.Copy(src, dest, copy.Options{
+							Skip: func(stat fs.FileInfo, src, dest string) (bool, error) {
+								if !isExcludedPath(src, "node_modules", ".git") && (stat.IsDir() || isIncludedExt(filepath.Base(src), "*.js", "*.ts")) {
+									return false, nil
+								}
+
+								return true, nil
+							},
+							Sync: true,
+						})
+
+						if err != nil {
+							p.warn(StageLink, fmt.Sprintf("Failed to copy %s: %v", k, err), err)
+						}
+
+						select {
+						case requestBuildCh <- struct{}{}:
+						case <-ctx.Done():
+						}
+					}
+				}
+			case err := <-w.Errors():
+				p.warn(StageLink, err.Error(), err)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	p.info(StageLink, fmt.Sprintf("Watching packages in %s", from))
+
+	return requestBuildCh, nil
+}
+
+func isExcludedPath(srcPath string, exPaths ...string) bool {
+	for _, exP := range exPaths {
+		if strings.Contains(srcPath, exP) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIncludedExt(srcPath string, extensions ...string) bool {
+	for _, ext := range extensions {
+		if ok, _ := filepath.Match(ext, srcPath); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readFileContent(path string) (string, error) {
+	pkgData, err := os.ReadFile(path)
+	return string(pkgData), err
+}
@@ -0,0 +1,363 @@
+package gowebbuild
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/trading-peter/gowebbuild/fsutils"
+)
+
+// hashedEntryNames is the entryNames template used by hashNames. Chunks and assets are hashed by esbuild by default.
+const hashedEntryNames = "[dir]/[name]-[hash]"
+
+// ManifestPath returns the file the asset manifest of the setup is written to: Options.Manifest
+// or manifest.json in the output folder if only hashNames is set. It's empty if there is no manifest.
+func ManifestPath(opts Options) string {
+	if opts.Manifest != "" {
+		return opts.Manifest
+	}
+
+	if opts.HashNames && opts.ESBuild.Outdir != "" {
+		return filepath.Join(opts.ESBuild.Outdir, "manifest.json")
+	}
+
+	return ""
+}
+
+// Manifest maps the logical names of the output files (like index.js) to the files esbuild wrote (like index-5HQX2KJB.js).
+// Both are relative to the output folder.
+type Manifest map[string]string
+
+// manifest writes the asset manifest of the last esbuild run and points the script and link tags
+// of the HTML files in the output folder at the hashed files.
+func (p *Pipeline) manifest() error {
+	file := ManifestPath(p.Options)
+	if file == "" {
+		return nil
+	}
+
+	manifest := p.outputManifest()
+	if manifest == nil {
+		return fmt.Errorf("no metafile, the esbuild stage has to run first")
+	}
+
+	p.removeSuperseded(file, manifest)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	p.emit(Event{Type: EventFileWritten, Stage: StageManifest, Path: file, Count: len(manifest), Message: fmt.Sprintf("Wrote manifest %s with %d file(s)", file, len(manifest))})
+
+	return p.rewriteHTMLFiles(manifest)
+}
+
+// outputManifest returns the manifest of the last esbuild run, or nil if there is no metafile.
+func (p *Pipeline) outputManifest() Manifest {
+	meta := p.metafile()
+	if meta == nil {
+		return nil
+	}
+
+	// Dynamically imported files show up with an entry point in the metafile too, but they are named like chunks.
+	entryPoints := map[string]bool{}
+	for _, entry := range p.Options.ESBuild.EntryPoints {
+		entryPoints[entry] = true
+	}
+	for _, entry := range p.Options.ESBuild.EntryPointsAdvanced {
+		entryPoints[entry.In] = true
+	}
+	for _, page := range p.pages {
+		for _, ref := range page.refs {
+			entryPoints[ref.entry] = true
+		}
+	}
+
+	return buildManifest(p.Options, meta, entryPoints, p.absPath)
+}
+
+// removeSuperseded deletes the output files of the previous manifest that the new one doesn't list anymore. Without a purge before
+// every build (like in watch mode), a rebuild would leave the files with the previous hashes behind.
+func (p *Pipeline) removeSuperseded(file string, manifest Manifest) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	previous := Manifest{}
+	if json.Unmarshal(data, &previous) != nil {
+		return
+	}
+
+	current := map[string]bool{}
+	for _, out := range manifest {
+		current[out] = true
+	}
+
+	for _, out := range previous {
+		if current[out] {
+			continue
+		}
+
+		path := filepath.Join(p.Options.ESBuild.Outdir, filepath.FromSlash(out))
+		if !isWithin(path, p.Options.ESBuild.Outdir) || !fsutils.IsFile(path) {
+			continue
+		}
+
+		p.emit(Event{Type: EventFilePurged, Stage: StageManifest, Path: path, Message: fmt.Sprintf("Removing superseded %s", path)})
+		for _, ext := range []string{"", ".map", ".gz", ".br"} {
+			if err := os.Remove(path + ext); err != nil && !os.IsNotExist(err) {
+				p.warn(StageManifest, fmt.Sprintf("Failed to remove %s: %v", path+ext, err), err)
+			}
+		}
+	}
+}
+
+// buildManifest derives the logical name of every output file by cutting the hash out of it,
+// using the naming template esbuild used for the file.
+func buildManifest(opts Options, meta *Metafile, entryPoints map[string]bool, abs func(string) string) Manifest {
+	outdir := opts.ESBuild.Outdir
+	es := ESBuildOptions(opts)
+
+	entryNames := namesRegexp(defaultString(es.EntryNames, "[dir]/[name]"))
+	chunkNames := namesRegexp(defaultString(es.ChunkNames, "[name]-[hash]"))
+	assetNames := namesRegexp(defaultString(es.AssetNames, "[name]-[hash]"))
+
+	// CSS bundles of entry points are named like their entry point.
+	cssBundles := map[string]bool{}
+	for _, o := range meta.Outputs {
+		if o.CSSBundle != "" {
+			cssBundles[o.CSSBundle] = true
+		}
+	}
+
+	manifest := Manifest{}
+	for out, o := range meta.Outputs {
+		if strings.HasSuffix(out, ".map") {
+			continue
+		}
+
+		rel, err := filepath.Rel(outdir, abs(out))
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		var patterns []*regexp.Regexp
+		switch {
+		case entryPoints[abs(o.EntryPoint)] || cssBundles[out]:
+			patterns = []*regexp.Regexp{entryNames, chunkNames}
+		case isCodeFile(out):
+			patterns = []*regexp.Regexp{chunkNames, entryNames, assetNames}
+		default:
+			patterns = []*regexp.Regexp{assetNames, chunkNames}
+		}
+
+		manifest[logicalName(rel, patterns)] = rel
+	}
+
+	return manifest
+}
+
+var namePlaceholder = regexp.MustCompile(`[-_.]?\[hash\]|\[dir\]/?|\[name\]|\[ext\]`)
+
+// namesRegexp turns an esbuild naming template like [dir]/[name]-[hash] into a regular expression
+// that matches the output paths and captures the hash (with the separator in front of it).
+func namesRegexp(template string) *regexp.Regexp {
+	pattern := "^"
+	last := 0
+
+	for _, m := range namePlaceholder.FindAllStringIndex(template, -1) {
+		pattern += regexp.QuoteMeta(template[last:m[0]])
+
+		switch placeholder := template[m[0]:m[1]]; {
+		case strings.HasSuffix(placeholder, "[hash]"):
+			pattern += "(" + regexp.QuoteMeta(strings.TrimSuffix(placeholder, "[hash]")) + "[A-Z2-7]{8})"
+		case placeholder == "[dir]/":
+			pattern += "(?:.*/)?"
+		case placeholder == "[dir]":
+			pattern += ".*"
+		default:
+			pattern += "[^/]+?"
+		}
+
+		last = m[1]
+	}
+
+	// esbuild appends the extension of the file to the template.
+	pattern += regexp.QuoteMeta(template[last:]) + `(?:\.[^/]*)?$`
+	return regexp.MustCompile(pattern)
+}
+
+// logicalName removes the hash from an output path using the first naming template that matches it.
+// Files that are named by their hash only (like chunks/[hash]) keep their name.
+func logicalName(rel string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		loc := re.FindStringSubmatchIndex(rel)
+		if loc == nil {
+			continue
+		}
+
+		if len(loc) < 4 || loc[2] < 0 {
+			return rel
+		}
+
+		name := strings.TrimPrefix(path.Clean(rel[:loc[2]]+rel[loc[3]:]), "/")
+		if base := path.Base(name); base == "." || strings.HasPrefix(base, ".") {
+			return rel
+		}
+
+		return name
+	}
+
+	return rel
+}
+
+func isCodeFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".js", ".mjs", ".cjs", ".css":
+		return true
+	}
+
+	return false
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+
+	return s
+}
+
+// rewriteHTMLFiles replaces the logical names in the src of script tags and the href of link tags
+// of all HTML files in the output folder with the hashed names.
+func (p *Pipeline) rewriteHTMLFiles(manifest Manifest) error {
+	outdir := p.Options.ESBuild.Outdir
+	if outdir == "" {
+		return nil
+	}
+
+	return filepath.WalkDir(outdir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(file), ".html") {
+			return err
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+		if err != nil {
+			p.warn(StageManifest, fmt.Sprintf("Failed to parse %s: %v", file, err), err)
+			return nil
+		}
+
+		count := 0
+		rewrite := func(s *goquery.Selection, attr string) {
+			ref, ok := s.Attr(attr)
+			if !ok {
+				return
+			}
+
+			if hashed, ok := manifest.rewriteRef(ref, filepath.Dir(file), outdir); ok {
+				s.SetAttr(attr, hashed)
+				count++
+			}
+		}
+
+		doc.Find("script[src]").Each(func(i int, s *goquery.Selection) { rewrite(s, "src") })
+		doc.Find("link[href]").Each(func(i int, s *goquery.Selection) { rewrite(s, "href") })
+
+		if count == 0 {
+			return nil
+		}
+
+		var buf bytes.Buffer
+		if err := goquery.Render(&buf, doc.Selection); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+
+		p.emit(Event{Type: EventFileWritten, Stage: StageManifest, Path: file, Count: count, Message: fmt.Sprintf("Rewrote %d reference(s) in %s", count, file)})
+		return nil
+	})
+}
+
+// rewriteRef returns the reference to the hashed file for a reference to a logical name.
+// Relative references are resolved from the folder of the HTML file, absolute ones (like /js/index.js)
+// only have to end with the logical name.
+func (m Manifest) rewriteRef(ref, htmlDir, outdir string) (string, bool) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return ref, false
+	}
+
+	if strings.HasPrefix(u.Path, "/") {
+		// Prefer the longest logical name, js/index.js over index.js.
+		names := make([]string, 0, len(m))
+		for n := range m {
+			names = append(names, n)
+		}
+		sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+		for _, n := range names {
+			if strings.HasSuffix(u.Path, "/"+n) {
+				if m[n] == n {
+					return ref, false
+				}
+
+				u.Path = strings.TrimSuffix(u.Path, n) + m[n]
+				return u.String(), true
+			}
+		}
+
+		return ref, false
+	}
+
+	name, err := filepath.Rel(outdir, filepath.Join(htmlDir, filepath.FromSlash(u.Path)))
+	if err != nil {
+		return ref, false
+	}
+
+	hashed, ok := m[filepath.ToSlash(name)]
+	if !ok || hashed == filepath.ToSlash(name) {
+		return ref, false
+	}
+
+	// The HTML file may be in a subfolder of the output folder, the reference stays relative to it.
+	rel, err := filepath.Rel(htmlDir, filepath.Join(outdir, filepath.FromSlash(hashed)))
+	if err != nil {
+		return ref, false
+	}
+
+	u.Path = filepath.ToSlash(rel)
+	if strings.HasPrefix(ref, "./") {
+		u.Path = "./" + u.Path
+	}
+
+	return u.String(), true
+}
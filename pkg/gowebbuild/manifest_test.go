@@ -0,0 +1,81 @@
+package gowebbuild
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestNamesRegexp(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		// want is the logical name, the path itself if the template doesn't match or the file is named by its hash only.
+		want string
+	}{
+		{template: "[dir]/[name]-[hash]", path: "index-5HQX2KJB.js", want: "index.js"},
+		{template: "[dir]/[name]-[hash]", path: "admin/index-5HQX2KJB.js", want: "admin/index.js"},
+		{template: "[dir]/[name]-[hash]", path: "index-5HQX2KJB.css", want: "index.css"},
+		{template: "[dir]/[name]-[hash]", path: "my-app-5HQX2KJB.js", want: "my-app.js"},
+		{template: "[dir]/[name]-[hash]", path: "index.js", want: "index.js"},
+		{template: "[name].[hash]", path: "logo.ABCDEFGH.svg", want: "logo.svg"},
+		{template: "[name]_[hash]", path: "logo_ABCDEFGH.svg", want: "logo.svg"},
+		{template: "assets/[name]-[hash]", path: "assets/logo-ABCDEFGH.png", want: "assets/logo.png"},
+		{template: "assets/[name]-[hash]", path: "logo-ABCDEFGH.png", want: "logo-ABCDEFGH.png"},
+		{template: "chunks/[hash]", path: "chunks/ABCDEFGH.js", want: "chunks/ABCDEFGH.js"},
+		{template: "[hash]/[name]", path: "ABCDEFGH/index.js", want: "index.js"},
+		// Not a hash: lower case and too short.
+		{template: "[name]-[hash]", path: "index-abcdefgh.js", want: "index-abcdefgh.js"},
+		{template: "[name]-[hash]", path: "index-ABC.js", want: "index-ABC.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template+" "+tt.path, func(t *testing.T) {
+			if got := logicalName(tt.path, []*regexp.Regexp{namesRegexp(tt.template)}); got != tt.want {
+				t.Errorf("logicalName(%q) with %s (%s) = %q, want %q", tt.path, tt.template, namesRegexp(tt.template), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteRef(t *testing.T) {
+	outdir := filepath.FromSlash("/site/dist")
+	m := Manifest{
+		"index.js":       "index-5HQX2KJB.js",
+		"js/index.js":    "js/index-7TQ3NUZA.js",
+		"admin/app.css":  "admin/app-LQ2MZ3RX.css",
+		"unchanged.js":   "unchanged.js",
+		"chunks/ABCD.js": "chunks/ABCD.js",
+	}
+
+	tests := []struct {
+		ref     string
+		htmlDir string
+		want    string
+		ok      bool
+	}{
+		{ref: "index.js", htmlDir: "/site/dist", want: "index-5HQX2KJB.js", ok: true},
+		{ref: "./index.js", htmlDir: "/site/dist", want: "./index-5HQX2KJB.js", ok: true},
+		{ref: "../index.js", htmlDir: "/site/dist/admin", want: "../index-5HQX2KJB.js", ok: true},
+		{ref: "app.css", htmlDir: "/site/dist/admin", want: "app-LQ2MZ3RX.css", ok: true},
+		{ref: "index.js?v=1#top", htmlDir: "/site/dist", want: "index-5HQX2KJB.js?v=1#top", ok: true},
+		// Absolute references only have to end with the logical name, the longest one wins.
+		{ref: "/js/index.js", htmlDir: "/site/dist", want: "/js/index-7TQ3NUZA.js", ok: true},
+		{ref: "/static/index.js", htmlDir: "/site/dist", want: "/static/index-5HQX2KJB.js", ok: true},
+		{ref: "/static/myindex.js", htmlDir: "/site/dist", want: "/static/myindex.js"},
+		{ref: "https://cdn.example.com/index.js", htmlDir: "/site/dist", want: "https://cdn.example.com/index.js"},
+		{ref: "//cdn.example.com/index.js", htmlDir: "/site/dist", want: "//cdn.example.com/index.js"},
+		{ref: "unchanged.js", htmlDir: "/site/dist", want: "unchanged.js"},
+		{ref: "other.js", htmlDir: "/site/dist", want: "other.js"},
+		{ref: "#anchor", htmlDir: "/site/dist", want: "#anchor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, ok := m.rewriteRef(tt.ref, filepath.FromSlash(tt.htmlDir), outdir)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("rewriteRef(%q, %q) = %q, %t, want %q, %t", tt.ref, tt.htmlDir, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
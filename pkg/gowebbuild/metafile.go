@@ -0,0 +1,94 @@
+package gowebbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Metafile is the part of esbuild's metafile that describes what ended up in the output files,
+// see https://esbuild.github.io/api/#metafile.
+type Metafile struct {
+	Inputs  map[string]MetafileInput  `json:"inputs"`
+	Outputs map[string]MetafileOutput `json:"outputs"`
+}
+
+// MetafileInput is a source file of the build.
+type MetafileInput struct {
+	Bytes int `json:"bytes"`
+}
+
+// MetafileOutput is a file written by the build, with the number of bytes each input contributed to it.
+type MetafileOutput struct {
+	Bytes      int    `json:"bytes"`
+	EntryPoint string `json:"entryPoint"`
+	CSSBundle  string `json:"cssBundle"`
+	Inputs     map[string]struct {
+		BytesInOutput int `json:"bytesInOutput"`
+	} `json:"inputs"`
+}
+
+// ReadMetafile reads a metafile written by esbuild.
+func ReadMetafile(path string) (*Metafile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Metafile{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return meta, nil
+}
+
+// PackageName returns the npm package an input of a metafile belongs to (like lit or @lit/reactive-element),
+// or an empty string for inputs outside of node_modules.
+func PackageName(input string) string {
+	i := strings.LastIndex(input, "node_modules/")
+	if i < 0 {
+		return ""
+	}
+
+	parts := strings.SplitN(input[i+len("node_modules/"):], "/", 3)
+	if strings.HasPrefix(parts[0], "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+
+	return parts[0]
+}
+
+// metafile returns the metafile of the last esbuild run, or nil if there is none.
+func (p *Pipeline) metafile() *Metafile {
+	if p.result == nil {
+		return nil
+	}
+
+	return parseMetafile(p.result.Metafile)
+}
+
+func parseMetafile(data string) *Metafile {
+	meta := &Metafile{}
+	if data == "" || json.Unmarshal([]byte(data), meta) != nil {
+		return nil
+	}
+
+	return meta
+}
+
+// absPath resolves a path of the metafile. They are relative to the working directory of esbuild.
+func (p *Pipeline) absPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	wd := p.Options.ESBuild.AbsWorkingDir
+	if wd == "" {
+		wd, _ = os.Getwd()
+	}
+
+	return filepath.Join(wd, path)
+}
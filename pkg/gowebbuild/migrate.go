@@ -0,0 +1,212 @@
+package gowebbuild
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var yamlMarshalerType = reflect.TypeOf((*yaml.Marshaler)(nil)).Elem()
+
+// MigrateConfig converts a JSON or old-style config (integer enum values) to the current YAML format.
+// It returns the config before and after the migration, both written as YAML the same way,
+// so a diff of the two only shows what the migration changed.
+func MigrateConfig(path string) (before, after string, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return "", "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(doc.Content) == 0 {
+		return "", "", fmt.Errorf("%s is empty", path)
+	}
+
+	if filepath.Ext(path) == ".json" {
+		resetStyle(doc.Content[0])
+	}
+
+	before, err = EncodeYAML(&doc)
+	if err != nil {
+		return "", "", err
+	}
+
+	migrateCfg(doc.Content[0])
+
+	after, err = EncodeYAML(&doc)
+	return before, after, err
+}
+
+// migrateCfg converts a parsed config in place: keys are matched case-insensitively against the schema
+// (the JSON format used the Go field names, e.g. ESBuild.EntryPoints) and integer enum values are replaced by their names.
+func migrateCfg(root *yaml.Node) {
+	normalizeCfgKeys(root)
+	migrateNode(root, cfgRootType(root))
+}
+
+// normalizeCfgKeys renames keys that only differ in case from the schema, like the keys of JSON configs.
+func normalizeCfgKeys(root *yaml.Node) {
+	normalizeKeys(root, cfgRootType(root))
+}
+
+// cfgRootType returns the type a config file decodes into: a document, a list of setups or a single setup.
+func cfgRootType(root *yaml.Node) reflect.Type {
+	if root.Kind == yaml.SequenceNode {
+		return reflect.TypeOf([]Options{})
+	}
+
+	if root.Kind == yaml.MappingNode {
+		for i := 0; i < len(root.Content)-1; i += 2 {
+			key := root.Content[i].Value
+			if strings.EqualFold(key, "setups") || strings.EqualFold(key, "defaults") || strings.EqualFold(key, "include") {
+				return reflect.TypeOf(cfgDocument{})
+			}
+		}
+	}
+
+	return reflect.TypeOf(Options{})
+}
+
+func normalizeKeys(node *yaml.Node, t reflect.Type) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+
+		for i := 0; i < len(node.Content)-1; i += 2 {
+			key := node.Content[i]
+
+			field, ok := fields[key.Value]
+			if !ok {
+				for name, f := range fields {
+					if strings.EqualFold(name, key.Value) || strings.EqualFold(f.Name, key.Value) {
+						key.Value = name
+						field, ok = f, true
+						break
+					}
+				}
+			}
+
+			if ok {
+				normalizeKeys(node.Content[i+1], field.Type)
+			}
+		}
+
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, item := range node.Content {
+			normalizeKeys(item, t.Elem())
+		}
+
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 1; i < len(node.Content); i += 2 {
+			normalizeKeys(node.Content[i], t.Elem())
+		}
+	}
+}
+
+// migrateNode replaces integer enum values by their names. Values that are the esbuild default are removed.
+func migrateNode(node *yaml.Node, t reflect.Type) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		content := []*yaml.Node{}
+
+		for i := 0; i < len(node.Content)-1; i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			if field, ok := fields[key.Value]; ok {
+				if isLegacyEnum(value, field.Type) {
+					if !migrateEnum(value, field.Type) {
+						continue
+					}
+				} else {
+					migrateNode(value, field.Type)
+				}
+			}
+
+			content = append(content, key, value)
+		}
+
+		node.Content = content
+
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, item := range node.Content {
+			migrateNode(item, t.Elem())
+		}
+
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 1; i < len(node.Content); i += 2 {
+			migrateNode(node.Content[i], t.Elem())
+		}
+	}
+}
+
+func isLegacyEnum(node *yaml.Node, t reflect.Type) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!int" &&
+		reflect.PointerTo(t).Implements(unmarshalerType) && t.Implements(yamlMarshalerType)
+}
+
+// migrateEnum decodes a legacy enum value and replaces it by the encoded name.
+// It returns false if the value is the default and can be dropped.
+func migrateEnum(node *yaml.Node, t reflect.Type) bool {
+	v := reflect.New(t)
+	if err := node.Decode(v.Interface()); err != nil {
+		// Leave invalid values alone, config validate reports them.
+		return true
+	}
+
+	name, err := v.Elem().Interface().(yaml.Marshaler).MarshalYAML()
+	if err != nil || name == nil || name == "" {
+		return false
+	}
+
+	encoded := yaml.Node{}
+	if err := encoded.Encode(name); err != nil {
+		return true
+	}
+
+	encoded.HeadComment, encoded.LineComment, encoded.FootComment = node.HeadComment, node.LineComment, node.FootComment
+	*node = encoded
+	return true
+}
+
+// resetStyle drops the JSON flow and quoting style, so the node is written as block YAML.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+// EncodeYAML writes node as YAML with the 2 space indentation of the configs.
+func EncodeYAML(node *yaml.Node) (string, error) {
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(node); err != nil {
+		return "", err
+	}
+
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
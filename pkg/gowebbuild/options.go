@@ -0,0 +1,526 @@
+package gowebbuild
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/trading-peter/gowebbuild/fsutils"
+	"gopkg.in/yaml.v3"
+)
+
+// ESBuildOptions converts the esbuild settings of a setup into the build options that are handed to esbuild.
+func ESBuildOptions(cfg Options) api.BuildOptions {
+	es := cfg.ESBuild
+
+	buildOptions := api.BuildOptions{
+		LogLevel:    api.LogLevel(es.LogLevel),
+		LogLimit:    es.LogLimit,
+		LogOverride: map[string]api.LogLevel{},
+
+		Sourcemap:  api.SourceMap(es.Sourcemap),
+		SourceRoot: es.SourceRoot,
+
+		Supported: es.Supported,
+
+		MangleProps:       es.MangleProps,
+		ReserveProps:      es.ReserveProps,
+		MangleCache:       es.MangleCache,
+		Drop:              api.Drop(es.Drop),
+		DropLabels:        es.DropLabels,
+		MinifyWhitespace:  es.Minify || es.MinifyWhitespace,
+		MinifyIdentifiers: es.Minify || es.MinifyIdentifiers,
+		MinifySyntax:      es.Minify || es.MinifySyntax,
+		LineLimit:         es.LineLimit,
+		Charset:           api.Charset(es.Charset),
+		IgnoreAnnotations: es.IgnoreAnnotations,
+		LegalComments:     api.LegalComments(es.LegalComments),
+
+		JSX:             api.JSX(es.JSX),
+		JSXFactory:      es.JSXFactory,
+		JSXFragment:     es.JSXFragment,
+		JSXImportSource: es.JSXImportSource,
+		JSXDev:          es.JSXDev,
+		JSXSideEffects:  es.JSXSideEffects,
+
+		Define:    es.Define,
+		Pure:      es.Pure,
+		KeepNames: es.KeepNames,
+
+		GlobalName:        es.GlobalName,
+		Bundle:            es.Bundle,
+		PreserveSymlinks:  es.PreserveSymlinks,
+		Splitting:         es.Splitting,
+		Outfile:           es.Outfile,
+		Metafile:          es.Metafile || cfg.Metafile != "" || len(cfg.Budgets.EntryPoints) > 0 || ManifestPath(cfg) != "",
+		Outdir:            es.Outdir,
+		Outbase:           es.Outbase,
+		AbsWorkingDir:     es.AbsWorkingDir,
+		Platform:          api.Platform(es.Platform),
+		Format:            api.Format(es.Format),
+		External:          es.External,
+		Packages:          api.Packages(es.Packages),
+		Alias:             es.Alias,
+		MainFields:        es.MainFields,
+		Conditions:        es.Conditions,
+		Loader:            map[string]api.Loader{},
+		ResolveExtensions: es.ResolveExtensions,
+		Tsconfig:          es.Tsconfig,
+		TsconfigRaw:       es.TsconfigRaw,
+		OutExtension:      es.OutExtension,
+		PublicPath:        es.PublicPath,
+		Inject:            es.Inject,
+		Banner:            es.Banner,
+		Footer:            es.Footer,
+		NodePaths:         es.NodePaths,
+
+		EntryNames: es.EntryNames,
+		ChunkNames: es.ChunkNames,
+		AssetNames: es.AssetNames,
+
+		EntryPoints: es.EntryPoints,
+
+		Write:          es.Write,
+		AllowOverwrite: es.AllowOverwrite,
+	}
+
+	if es.Color != nil {
+		buildOptions.Color = api.ColorNever
+		if *es.Color {
+			buildOptions.Color = api.ColorAlways
+		}
+	}
+
+	for k, v := range es.LogOverride {
+		buildOptions.LogOverride[k] = api.LogLevel(v)
+	}
+
+	if es.SourcesContent != nil && !*es.SourcesContent {
+		buildOptions.SourcesContent = api.SourcesContentExclude
+	}
+
+	if es.MangleQuoted {
+		buildOptions.MangleQuoted = api.MangleQuotedTrue
+	}
+
+	if es.TreeShaking != nil {
+		buildOptions.TreeShaking = api.TreeShakingFalse
+		if *es.TreeShaking {
+			buildOptions.TreeShaking = api.TreeShakingTrue
+		}
+	}
+
+	for ext, l := range es.Loader {
+		buildOptions.Loader[ext] = api.Loader(l)
+	}
+
+	for _, ep := range es.EntryPointsAdvanced {
+		buildOptions.EntryPointsAdvanced = append(buildOptions.EntryPointsAdvanced, api.EntryPoint{
+			InputPath:  ep.In,
+			OutputPath: ep.Out,
+		})
+	}
+
+	if es.Stdin != nil {
+		buildOptions.Stdin = &api.StdinOptions{
+			Contents:   es.Stdin.Contents,
+			ResolveDir: es.Stdin.ResolveDir,
+			Sourcefile: es.Stdin.Sourcefile,
+			Loader:     api.Loader(es.Stdin.Loader),
+		}
+	}
+
+	if cfg.HashNames && buildOptions.EntryNames == "" {
+		buildOptions.EntryNames = hashedEntryNames
+	}
+
+	buildOptions.Target = es.Target.Target
+	buildOptions.Engines = es.Target.Engines
+
+	// Default to modern ES for decorator support if no target is specified
+	if buildOptions.Target == api.DefaultTarget && len(buildOptions.Engines) == 0 {
+		buildOptions.Target = api.ES2022
+	}
+
+	return buildOptions
+}
+
+// Options is a single setup of a config file: what to build with esbuild and what to copy, download, replace,
+// watch and serve around it.
+type Options struct {
+	Extends    StringList         `yaml:"extends" desc:"Config files this setup inherits from. Each one must contain a single setup or defaults."`
+	Name       string             `yaml:"name" desc:"Name of the setup, used to select setups with --only and --skip."`
+	DependsOn  StringList         `yaml:"dependsOn" desc:"Names of setups that have to be built before this one."`
+	Profiles   map[string]Options `yaml:"profiles" desc:"Named sets of settings (like dev, staging or prod) that are merged over the setup when the profile is selected with --profile."`
+	Production bool               `yaml:"production" desc:"Run downloads before and productionBuildOptions.cmdPostBuild after the build. Enabled by the built-in prod profile."`
+	ESBuild    struct {
+		EntryPoints         []string `yaml:"entryPoints" path:"true" desc:"Files (or glob patterns) esbuild uses as the entry points of the bundle."`
+		EntryPointsAdvanced []struct {
+			In  string `yaml:"in" path:"true" desc:"Path of the entry point."`
+			Out string `yaml:"out" desc:"Output path of the entry point, relative to outdir and without extension."`
+		} `yaml:"entryPointsAdvanced" desc:"Entry points with a custom output path."`
+		Outdir           string              `yaml:"outdir" path:"true" desc:"Output directory for the build."`
+		Outbase          string              `yaml:"outbase" path:"true" desc:"Base directory that output paths of entry points are computed relative to."`
+		Outfile          string              `yaml:"outfile" path:"true" desc:"Output file, only usable with a single entry point."`
+		Sourcemap        SourceMap           `yaml:"sourcemap" desc:"How to generate source maps."`
+		SourceRoot       string              `yaml:"sourceRoot" desc:"Value of the sourceRoot field in generated source maps."`
+		SourcesContent   *bool               `yaml:"sourcesContent" desc:"Include the original sources in the source maps (default true)."`
+		Format           Format              `yaml:"format" desc:"Output format of the generated JavaScript files."`
+		Splitting        bool                `yaml:"splitting" desc:"Enable code splitting (only works with the esm format)."`
+		Platform         Platform            `yaml:"platform" desc:"Platform the code is built for."`
+		Bundle           bool                `yaml:"bundle" desc:"Inline imported dependencies into the output files."`
+		Write            bool                `yaml:"write" desc:"Write the output files to disk."`
+		AllowOverwrite   bool                `yaml:"allowOverwrite" desc:"Allow output files to overwrite input files."`
+		Metafile         bool                `yaml:"metafile" desc:"Write esbuild's metadata about the build to metafile (or to the cache folder if it isn't set), for gowebbuild analyze."`
+		AbsWorkingDir    string              `yaml:"absWorkingDir" path:"true" desc:"Working directory of esbuild."`
+		Color            *bool               `yaml:"color" desc:"Use colors in esbuild's terminal output (default is to detect a terminal)."`
+		LogLevel         LogLevel            `yaml:"logLevel" desc:"Verbosity of esbuild's terminal output."`
+		LogLimit         int                 `yaml:"logLimit" desc:"Maximum number of log messages esbuild prints (0 means no limit)."`
+		LogOverride      map[string]LogLevel `yaml:"logOverride" desc:"Log level per esbuild message id."`
+		Target           Target              `yaml:"target" desc:"Language target and/or engine versions, e.g. es2022 or [es2020, chrome100, safari15.4]. Defaults to es2022."`
+		Supported        map[string]bool     `yaml:"supported" desc:"Override which syntax features esbuild considers supported."`
+		PurgeBeforeBuild bool                `yaml:"purgeBeforeBuild" desc:"Delete outdir/outfile before building."`
+
+		Minify            bool           `yaml:"minify" desc:"Shorthand for minifyWhitespace, minifyIdentifiers and minifySyntax."`
+		MinifyWhitespace  bool           `yaml:"minifyWhitespace" desc:"Remove unnecessary whitespace."`
+		MinifyIdentifiers bool           `yaml:"minifyIdentifiers" desc:"Shorten local identifiers."`
+		MinifySyntax      bool           `yaml:"minifySyntax" desc:"Rewrite syntax to be more compact."`
+		LineLimit         int            `yaml:"lineLimit" desc:"Break output lines that are longer than this."`
+		Charset           Charset        `yaml:"charset" desc:"Charset of the output files."`
+		TreeShaking       *bool          `yaml:"treeShaking" desc:"Force tree shaking on or off."`
+		IgnoreAnnotations bool           `yaml:"ignoreAnnotations" desc:"Ignore side effect annotations like /* @__PURE__ */ and sideEffects in package.json."`
+		LegalComments     LegalComments  `yaml:"legalComments" desc:"What to do with legal comments."`
+		KeepNames         bool           `yaml:"keepNames" desc:"Preserve the name property of functions and classes when minifying."`
+		Drop              Drop           `yaml:"drop" desc:"Constructs to remove from the output."`
+		DropLabels        []string       `yaml:"dropLabels" desc:"Labeled statements to remove from the output."`
+		Pure              []string       `yaml:"pure" desc:"Global functions whose calls are considered side effect free."`
+		MangleProps       string         `yaml:"mangleProps" desc:"Regular expression of property names to mangle."`
+		ReserveProps      string         `yaml:"reserveProps" desc:"Regular expression of property names to never mangle."`
+		MangleQuoted      bool           `yaml:"mangleQuoted" desc:"Mangle quoted properties as well."`
+		MangleCache       map[string]any `yaml:"mangleCache" desc:"Fixed mangled names to use for properties."`
+
+		JSX             JSX    `yaml:"jsx" desc:"How JSX syntax is handled."`
+		JSXFactory      string `yaml:"jsxFactory" desc:"Function that is called for each JSX element."`
+		JSXFragment     string `yaml:"jsxFragment" desc:"Function that is called for each JSX fragment."`
+		JSXImportSource string `yaml:"jsxImportSource" desc:"Package to import the JSX runtime from (automatic jsx mode)."`
+		JSXDev          bool   `yaml:"jsxDev" desc:"Use the development JSX transform (automatic jsx mode)."`
+		JSXSideEffects  bool   `yaml:"jsxSideEffects" desc:"Don't mark JSX expressions as side effect free."`
+
+		Define            map[string]string `yaml:"define" desc:"Replace global identifiers with constant expressions."`
+		GlobalName        string            `yaml:"globalName" desc:"Name of the global variable that holds the exports (iife format)."`
+		PreserveSymlinks  bool              `yaml:"preserveSymlinks" desc:"Don't resolve symlinks to their real path."`
+		External          []string          `yaml:"external" desc:"Imports that are excluded from the bundle."`
+		Packages          Packages          `yaml:"packages" desc:"Whether package imports are bundled or kept external."`
+		Alias             map[string]string `yaml:"alias" desc:"Substitute packages with other packages."`
+		MainFields        []string          `yaml:"mainFields" desc:"Fields of package.json that are checked for the entry point of a package."`
+		Conditions        []string          `yaml:"conditions" desc:"Custom conditions used to resolve the exports field of package.json."`
+		Loader            map[string]Loader `yaml:"loader" desc:"Loader per file extension, e.g. .svg: text."`
+		ResolveExtensions []string          `yaml:"resolveExtensions" desc:"File extensions tried when resolving imports without an extension."`
+		NodePaths         []string          `yaml:"nodePaths" path:"true" desc:"Additional directories to search for packages."`
+		Tsconfig          string            `yaml:"tsconfig" path:"true" desc:"Path of a tsconfig file to use instead of the automatically detected one."`
+		TsconfigRaw       string            `yaml:"tsconfigRaw" desc:"Contents of a tsconfig file as a JSON string."`
+		OutExtension      map[string]string `yaml:"outExtension" desc:"Custom output file extension per default extension, e.g. .js: .mjs."`
+		PublicPath        string            `yaml:"publicPath" desc:"Prefix for the paths of files generated by the file loader."`
+		Inject            []string          `yaml:"inject" path:"true" desc:"Files that are automatically injected into every output file."`
+		Banner            map[string]string `yaml:"banner" desc:"Text to insert at the beginning of output files, per file type (js, css)."`
+		Footer            map[string]string `yaml:"footer" desc:"Text to insert at the end of output files, per file type (js, css)."`
+
+		EntryNames string `yaml:"entryNames" desc:"Naming template of output files for entry points, e.g. [dir]/[name]-[hash]."`
+		ChunkNames string `yaml:"chunkNames" desc:"Naming template of shared chunks created by code splitting."`
+		AssetNames string `yaml:"assetNames" desc:"Naming template of files generated by the file loader."`
+
+		Stdin *struct {
+			Contents   string `yaml:"contents" desc:"Source code of the entry point."`
+			ResolveDir string `yaml:"resolveDir" path:"true" desc:"Directory that imports are resolved from."`
+			Sourcefile string `yaml:"sourcefile" desc:"File name used in error messages and source maps."`
+			Loader     Loader `yaml:"loader" desc:"Loader used for the contents."`
+		} `yaml:"stdin" desc:"Use the given source code as entry point instead of a file."`
+	} `yaml:"esbuild" desc:"Options passed to esbuild, see https://esbuild.github.io/api/."`
+	Watch struct {
+		Paths            []string     `yaml:"paths" path:"true" desc:"Folders that are watched for changes."`
+		Exclude          []string     `yaml:"exclude" path:"true" desc:"Paths that are ignored by the watcher."`
+		InjectLiveReload string       `yaml:"injectLiveReload" path:"true" desc:"HTML file that the live reload script gets injected into."`
+		SkipCSPInject    bool         `yaml:"skipCSPInject" desc:"Don't add the live reload server to the Content-Security-Policy of the HTML file."`
+		Backend          WatchBackend `yaml:"backend" desc:"How changes are detected: native (inotify, polls paths that can't be watched natively) or poll. Default: native."`
+		Auto             bool         `yaml:"auto" desc:"Watch the files the last build read (from esbuild's metafile), the copy sources and the content swap files. Paths are watched in addition."`
+		Debounce         Duration     `yaml:"debounce" desc:"Quiet period after a change before a rebuild starts, like 100ms or 1s (default 100ms). Changes within it are built together."`
+	} `desc:"Watch mode settings."`
+	Serve struct {
+		Path string `yaml:"path" path:"true" desc:"Folder to serve in watch mode."`
+		Port int    `yaml:"port" desc:"Port of the http server (default 8080)."`
+	} `yaml:"serve" desc:"Serve a folder with a simple http server in watch mode."`
+	Copy []struct {
+		Src  string `yaml:"src" path:"true" desc:"File, folder or glob pattern to copy."`
+		Dest string `yaml:"dest" path:"true" desc:"Destination file or folder."`
+	} `yaml:"copy" desc:"Files to copy before every build."`
+	Download []struct {
+		Url  string `yaml:"url" desc:"URL to download."`
+		Dest string `yaml:"dest" path:"true" desc:"File the download is written to."`
+	} `yaml:"download" desc:"Files to download before production builds and with the download command."`
+	Replace     []ReplaceRule `yaml:"replace" desc:"Text replacements applied to files after every build."`
+	ContentSwap []struct {
+		File        string `yaml:"file" path:"true" desc:"Imported file whose content gets swapped."`
+		ReplaceWith string `yaml:"replaceWith" path:"true" desc:"File whose content is used instead."`
+	} `yaml:"contentSwap" desc:"Replace the content of imported files during the build."`
+	Link struct {
+		From string `yaml:"from" path:"true" desc:"Folder that contains the source code of linked npm packages."`
+		To   string `yaml:"to" path:"true" desc:"Project folder whose node_modules are updated when linked packages change."`
+	} `yaml:"link" desc:"Sync changes of local npm packages into node_modules in watch mode."`
+	Budgets                Budgets  `yaml:"budgets" desc:"Size limits of the output files, checked by production builds."`
+	Metafile               string   `yaml:"metafile" path:"true" desc:"File esbuild's metafile is written to after every build (like ./meta.json), for gowebbuild analyze."`
+	Compress               Compress `yaml:"compress" desc:"Precompressed .gz and .br files of the output, written by production builds."`
+	HashNames              bool     `yaml:"hashNames" desc:"Add a content hash to the names of the entry point outputs ([dir]/[name]-[hash], unless esbuild.entryNames is set) and write a manifest."`
+	Manifest               string   `yaml:"manifest" path:"true" desc:"File the asset manifest is written to, mapping logical names to hashed output files (default with hashNames: manifest.json in outdir)."`
+	ProductionBuildOptions struct {
+		CmdPostBuild string `yaml:"cmdPostBuild" desc:"Shell command that is executed after a production build."`
+	} `yaml:"productionBuildOptions" desc:"Settings for production builds."`
+	NpmProxy struct {
+		Overrides []NpmProxyOverride `desc:"Package namespaces that are served from the local filesystem."`
+	} `yaml:"npm_proxy" desc:"Serve npm packages from the local filesystem."`
+}
+
+// StringList accepts a single string as well as a list of strings.
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+
+	list := []string{}
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+
+	*l = list
+	return nil
+}
+
+// WatchBackend selects how watch mode detects changes.
+type WatchBackend string
+
+const (
+	WatchNative WatchBackend = "native"
+	WatchPoll   WatchBackend = "poll"
+)
+
+var watchBackendNames = map[string]WatchBackend{
+	"native": WatchNative,
+	"poll":   WatchPoll,
+}
+
+func (b *WatchBackend) UnmarshalYAML(node *yaml.Node) error {
+	return decodeEnumYAML(node, "watch backend", watchBackendNames, b)
+}
+
+func (WatchBackend) jsonSchema() map[string]any {
+	return enumSchema(watchBackendNames)
+}
+
+// Duration is a time span. It can be configured like 150ms or 1.5s, or as a number of milliseconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: invalid duration, expected a duration like 150ms or 1s", node.Line)
+	}
+
+	if ms, err := strconv.ParseInt(node.Value, 10, 64); err == nil && ms >= 0 {
+		*d = Duration(time.Duration(ms) * time.Millisecond)
+		return nil
+	}
+
+	v, err := time.ParseDuration(node.Value)
+	if err != nil || v < 0 {
+		return fmt.Errorf("line %d: invalid duration %q, expected a duration like 150ms or 1s", node.Line, node.Value)
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+func (Duration) jsonSchema() map[string]any {
+	return map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "integer", "minimum": 0},
+			map[string]any{"type": "string", "pattern": `^(\d+(\.\d+)?(ns|us|µs|ms|s|m|h))+$`},
+			variableSchema,
+		},
+	}
+}
+
+// ReplaceRule replaces a text in all files matching a glob pattern.
+type ReplaceRule struct {
+	Pattern string `yaml:"pattern" path:"true" desc:"Glob pattern of the files to search in."`
+	Search  string `yaml:"search" desc:"Text to search for."`
+	Replace string `yaml:"replace" desc:"Replacement text."`
+}
+
+// NpmProxyOverride serves a package namespace from the local filesystem through the npm proxy.
+type NpmProxyOverride struct {
+	Namespace   string `yaml:"namespace" desc:"Package namespace, e.g. @my-org."`
+	Upstream    string `yaml:"upstream" desc:"Registry requests are forwarded to if a package isn't found locally."`
+	PackageRoot string `yaml:"packageRoot" path:"true" desc:"Folder that contains the sources of the packages."`
+}
+
+// SelectSetups filters the setups by name. If only is set, just the named setups are kept.
+// Setups named in skip are removed.
+func SelectSetups(optsSetups []Options, only, skip []string) ([]Options, error) {
+	names := map[string]bool{}
+	for _, o := range optsSetups {
+		if o.Name != "" {
+			names[o.Name] = true
+		}
+	}
+
+	for _, name := range append(append([]string{}, only...), skip...) {
+		if !names[name] {
+			return nil, fmt.Errorf("no setup named %q found in config", name)
+		}
+	}
+
+	selected := []Options{}
+
+	for _, o := range optsSetups {
+		if len(only) > 0 && !slices.Contains(only, o.Name) {
+			continue
+		}
+
+		if slices.Contains(skip, o.Name) {
+			continue
+		}
+
+		selected = append(selected, o)
+	}
+
+	return selected, nil
+}
+
+// SetupName returns the name of the i-th setup, or its position if it has no name.
+func SetupName(o Options, i int) string {
+	if o.Name != "" {
+		return o.Name
+	}
+
+	return fmt.Sprintf("#%d", i+1)
+}
+
+// processPaths makes the paths of a setup absolute.
+func processPaths(opts *Options) error {
+	errs := []error{}
+	resolve := func(path *string) {
+		abs, err := fsutils.AbsPath(*path)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		*path = abs
+	}
+
+	// ESBuild paths
+	for i := range opts.ESBuild.EntryPoints {
+		resolve(&opts.ESBuild.EntryPoints[i])
+	}
+	for i := range opts.ESBuild.EntryPointsAdvanced {
+		resolve(&opts.ESBuild.EntryPointsAdvanced[i].In)
+	}
+	resolve(&opts.ESBuild.Outdir)
+	resolve(&opts.ESBuild.Outfile)
+	resolve(&opts.ESBuild.AbsWorkingDir)
+	resolve(&opts.ESBuild.Tsconfig)
+	for i := range opts.ESBuild.Inject {
+		resolve(&opts.ESBuild.Inject[i])
+	}
+	for i := range opts.ESBuild.NodePaths {
+		resolve(&opts.ESBuild.NodePaths[i])
+	}
+	if opts.ESBuild.Stdin != nil {
+		resolve(&opts.ESBuild.Stdin.ResolveDir)
+	}
+
+	// Watch paths
+	for i := range opts.Watch.Paths {
+		resolve(&opts.Watch.Paths[i])
+	}
+
+	for i := range opts.Watch.Exclude {
+		resolve(&opts.Watch.Exclude[i])
+	}
+
+	resolve(&opts.Watch.InjectLiveReload)
+
+	// Serve path
+	resolve(&opts.Serve.Path)
+
+	// Copy paths
+	for i := range opts.Copy {
+		resolve(&opts.Copy[i].Src)
+		resolve(&opts.Copy[i].Dest)
+	}
+
+	// Download paths
+	for i := range opts.Download {
+		resolve(&opts.Download[i].Dest)
+	}
+
+	// Content swap paths
+	for i := range opts.ContentSwap {
+		resolve(&opts.ContentSwap[i].File)
+		resolve(&opts.ContentSwap[i].ReplaceWith)
+	}
+
+	// Replace paths
+	for i := range opts.Replace {
+		resolve(&opts.Replace[i].Pattern)
+	}
+
+	resolve(&opts.Metafile)
+	resolve(&opts.Manifest)
+
+	// Budget paths
+	for i := range opts.Budgets.Files {
+		resolve(&opts.Budgets.Files[i].Pattern)
+	}
+	for i := range opts.Budgets.EntryPoints {
+		resolve(&opts.Budgets.EntryPoints[i].EntryPoint)
+	}
+
+	// Link paths
+	resolve(&opts.Link.From)
+	resolve(&opts.Link.To)
+
+	// Npm proxy paths
+	for i := range opts.NpmProxy.Overrides {
+		resolve(&opts.NpmProxy.Overrides[i].PackageRoot)
+	}
+
+	return errors.Join(errs...)
+}
+
+// MetafilePath returns the file the metafile of the setup is written to: Options.Metafile or, if it isn't set,
+// a file named after the setup in the cache folder of the project in the working directory (see CacheDir).
+// The default is outside of the output folder, so the metafile isn't deployed or compressed.
+func MetafilePath(opts Options) string {
+	if opts.Metafile != "" {
+		return opts.Metafile
+	}
+
+	if opts.ESBuild.Outdir == "" && opts.ESBuild.Outfile == "" {
+		return ""
+	}
+
+	name := strings.NewReplacer("/", "-", "\\", "-").Replace(opts.Name)
+	if name == "" {
+		sum := sha256.Sum256([]byte(opts.ESBuild.Outdir + "\x00" + opts.ESBuild.Outfile))
+		name = fmt.Sprintf("%x", sum[:4])
+	}
+
+	return filepath.Join(CacheDir("."), "meta", name+".json")
+}
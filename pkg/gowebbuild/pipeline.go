@@ -0,0 +1,300 @@
+package gowebbuild
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// Stage is a step of the build pipeline of a setup.
+type Stage string
+
+const (
+	StageDownload         Stage = "download"
+	StagePurge            Stage = "purge"
+	StageCopy             Stage = "copy"
+	StageESBuild          Stage = "esbuild"
+	StageManifest         Stage = "manifest"
+	StageInjectLiveReload Stage = "inject-live-reload"
+	StageReplace          Stage = "replace"
+	StageBudgets          Stage = "budgets"
+	StageCompress         Stage = "compress"
+	StagePostBuild        Stage = "post-build"
+	StageLink             Stage = "link"
+)
+
+// EventType tells what an Event reports.
+type EventType int
+
+const (
+	// EventStageStarted is sent before a stage runs.
+	EventStageStarted EventType = iota
+	// EventStageFinished is sent after a stage ran, with its duration and error (if it failed).
+	EventStageFinished
+	// EventInfo is a progress message, like "Nothing to copy".
+	EventInfo
+	// EventWarning is a problem that doesn't stop the stage, like a copy rule whose source is missing.
+	EventWarning
+	// EventFilePurged is sent for every deleted output folder or file (Path).
+	EventFilePurged
+	// EventFileCopied is sent for every copied file or folder (Src to Dest).
+	EventFileCopied
+	// EventFileDownloaded is sent for every download (Src is the URL, Dest the file).
+	EventFileDownloaded
+	// EventFileReplaced is sent for every file a replace rule changed (Path, Count replacements).
+	EventFileReplaced
+	// EventFileWritten is sent for files written by other stages than esbuild, like the manifest or the HTML with the live reload script (Path).
+	EventFileWritten
+	// EventSizes is sent by the budgets stage with the sizes of the output files and the checked budgets.
+	EventSizes
+)
+
+// Event reports the progress of a pipeline. Message is a human readable description of the event
+// (empty for stage events).
+type Event struct {
+	Type     EventType
+	Setup    string
+	Stage    Stage
+	Message  string
+	Path     string
+	Src      string
+	Dest     string
+	Count    int
+	Duration time.Duration
+	Err      error
+	// BuildResult is the result of esbuild, set on the EventStageFinished event of the esbuild stage.
+	BuildResult *api.BuildResult
+	Sizes       []FileSize
+	Budgets     []BudgetResult
+}
+
+// Pipeline runs the stages of a single setup.
+type Pipeline struct {
+	Options        Options
+	Name           string
+	Stages         []Stage
+	LiveReloadPort uint
+	// Strict makes stages with warnings fail with a *WarningsError.
+	Strict   bool
+	plugins  []api.Plugin
+	onEvent  func(Event)
+	cmdOut   io.Writer
+	warnings atomic.Int64
+	// result of the last esbuild run, used by the budgets stage.
+	result *api.BuildResult
+	// pages are the HTML entry points of the last esbuild run.
+	pages []*htmlPage
+	// incremental pipelines build with an esbuild context, see WithIncremental.
+	incremental    bool
+	ctxMu          sync.Mutex
+	esbuildCtx     api.BuildContext
+	ctxEntryPoints []string
+	// inputs are the input files of the last esbuild run (nil if it failed) and plan the stages and rules of RunChanges.
+	// stateMu guards them and pages for Affects, which is called while the pipeline runs.
+	stateMu sync.Mutex
+	inputs  map[string]bool
+	plan    *plan
+	// watchInputs are the input files of the last successful esbuild run and errorFiles the files with errors of the last run, see WatchPaths.
+	watchInputs map[string]bool
+	errorFiles  []string
+}
+
+// PipelineOption configures a Pipeline.
+type PipelineOption func(*Pipeline)
+
+// WithName sets the setup name used in events and errors (default: Options.Name).
+func WithName(name string) PipelineOption {
+	return func(p *Pipeline) {
+		p.Name = name
+	}
+}
+
+// WithStages sets the stages that Run executes, in the given order.
+func WithStages(stages ...Stage) PipelineOption {
+	return func(p *Pipeline) {
+		p.Stages = stages
+	}
+}
+
+// WithLiveReloadPort sets the port of the live reload server whose script the inject-live-reload stage adds.
+func WithLiveReloadPort(port uint) PipelineOption {
+	return func(p *Pipeline) {
+		p.LiveReloadPort = port
+	}
+}
+
+// WithPlugins adds esbuild plugins to the esbuild stage.
+func WithPlugins(plugins ...api.Plugin) PipelineOption {
+	return func(p *Pipeline) {
+		p.plugins = append(p.plugins, plugins...)
+	}
+}
+
+// WithEventHandler sets a function that receives all events of the pipeline.
+// It's called synchronously from the goroutine running the pipeline.
+func WithEventHandler(fn func(Event)) PipelineOption {
+	return func(p *Pipeline) {
+		p.onEvent = fn
+	}
+}
+
+// WithCommandOutput sets where the output of the post build command goes (default: os.Stdout).
+func WithCommandOutput(w io.Writer) PipelineOption {
+	return func(p *Pipeline) {
+		p.cmdOut = w
+	}
+}
+
+// WithStrict makes stages fail when they report warnings, including the warnings of esbuild.
+func WithStrict() PipelineOption {
+	return func(p *Pipeline) {
+		p.Strict = true
+	}
+}
+
+// BuildStages are the stages of a build: downloads (production only), purge, copy, esbuild,
+// the asset manifest (if hashNames or manifest is set), replace, the budgets check (production only, if budgets are configured),
+// the precompressed files (production only, if compress is configured) and the post build command (production only).
+func BuildStages(opts Options) []Stage {
+	stages := []Stage{}
+
+	if opts.Production {
+		stages = append(stages, StageDownload)
+	}
+
+	stages = append(stages, StagePurge, StageCopy, StageESBuild)
+
+	if ManifestPath(opts) != "" {
+		stages = append(stages, StageManifest)
+	}
+
+	stages = append(stages, StageReplace)
+
+	if opts.Production && !opts.Budgets.empty() {
+		stages = append(stages, StageBudgets)
+	}
+
+	if opts.Production && opts.Compress.enabled() {
+		stages = append(stages, StageCompress)
+	}
+
+	if opts.Production {
+		stages = append(stages, StagePostBuild)
+	}
+
+	return stages
+}
+
+// WatchStages are the stages that run on every change in watch mode. The manifest stage does nothing
+// for setups without hashNames or manifest.
+func WatchStages() []Stage {
+	return []Stage{StagePurge, StageCopy, StageESBuild, StageManifest, StageInjectLiveReload, StageReplace}
+}
+
+// New creates the pipeline of a setup. By default it runs the BuildStages. Paths in opts have to be absolute,
+// like the ones of the setups LoadConfig returns.
+func New(opts Options, options ...PipelineOption) *Pipeline {
+	p := &Pipeline{
+		Options: opts,
+		Name:    opts.Name,
+		Stages:  BuildStages(opts),
+		cmdOut:  os.Stdout,
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	return p
+}
+
+// Run executes the stages of the pipeline in order and stops at the first failing one.
+// Stages that haven't started yet are skipped when ctx is done, an incremental esbuild stage is canceled.
+func (p *Pipeline) Run(ctx context.Context) error {
+	for _, stage := range p.Stages {
+		if err := p.RunStage(ctx, stage); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunStage executes a single stage. Errors are returned as *StageError.
+func (p *Pipeline) RunStage(ctx context.Context, stage Stage) error {
+	if err := ctx.Err(); err != nil {
+		return &StageError{Setup: p.Name, Stage: stage, Err: err}
+	}
+
+	p.emit(Event{Type: EventStageStarted, Stage: stage})
+	start := time.Now()
+	warnings := p.warnings.Load()
+
+	var err error
+	var result *api.BuildResult
+
+	switch stage {
+	case StageDownload:
+		err = p.download(ctx)
+	case StagePurge:
+		err = p.purge()
+	case StageCopy:
+		err = p.copy(ctx)
+	case StageESBuild:
+		result, err = p.esbuild(ctx)
+		p.result = result
+		p.updateInputs(err)
+	case StageManifest:
+		err = p.manifest()
+	case StageInjectLiveReload:
+		err = p.injectLiveReload()
+	case StageReplace:
+		err = p.replace(ctx)
+	case StageBudgets:
+		err = p.budgets()
+	case StageCompress:
+		err = p.compress()
+	case StagePostBuild:
+		err = p.postBuild(ctx)
+	default:
+		err = ErrUnknownStage
+	}
+
+	if result != nil {
+		p.warnings.Add(int64(len(result.Warnings)))
+	}
+
+	if count := p.warnings.Load() - warnings; err == nil && p.Strict && count > 0 {
+		err = &WarningsError{Count: int(count)}
+	}
+
+	if err != nil {
+		err = &StageError{Setup: p.Name, Stage: stage, Err: err}
+	}
+
+	p.emit(Event{Type: EventStageFinished, Stage: stage, Duration: time.Since(start), Err: err, BuildResult: result})
+	return err
+}
+
+func (p *Pipeline) emit(ev Event) {
+	if p.onEvent == nil {
+		return
+	}
+
+	ev.Setup = p.Name
+	p.onEvent(ev)
+}
+
+func (p *Pipeline) info(stage Stage, msg string) {
+	p.emit(Event{Type: EventInfo, Stage: stage, Message: msg})
+}
+
+func (p *Pipeline) warn(stage Stage, msg string, err error) {
+	p.warnings.Add(1)
+	p.emit(Event{Type: EventWarning, Stage: stage, Message: msg, Err: err})
+}
@@ -0,0 +1,508 @@
+package gowebbuild
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A config file is either a single setup, a list of setups or a document like this:
+//
+//	extends: ../shared/base.gowebbuild.yaml # defaults of the base file are merged under the defaults of this file
+//	include: [./admin/.gowebbuild.yaml]     # setups of other config files, resolved on their own
+//	defaults:                               # merged under every setup of this file
+//	  esbuild:
+//	    bundle: true
+//	setups:
+//	  - name: app
+//	    extends: ./app.base.yaml            # setups can extend other files as well
+//	    profiles:                           # merged over the setup if selected with --profile
+//	      staging:
+//	        esbuild:
+//	          minify: true
+//
+// Merge rules: mappings are merged key by key, scalars and lists of the overriding side win.
+// The rule lists copy, download, replace, contentSwap and npm_proxy.overrides are appended to the inherited ones instead.
+// Tag a list with !replace to replace an inherited rule list.
+// Relative paths are resolved relative to the file that declares them.
+type cfgDocument struct {
+	Extends  StringList `yaml:"extends" desc:"Config files whose defaults (or single setup) are inherited by the defaults of this file."`
+	Include  StringList `yaml:"include" desc:"Config files whose setups are added to the setups of this file."`
+	Defaults Options    `yaml:"defaults" desc:"Settings every setup of this file inherits."`
+	Setups   []Options  `yaml:"setups" desc:"The setups of this file."`
+}
+
+// prodProfile is the base of the prod profile. Profiles named prod in the config are merged over it.
+const prodProfile = `
+production: true
+esbuild:
+  minify: true
+  sourcemap: none
+`
+
+// Lists of rules that are appended to (instead of replacing) inherited lists.
+var appendedLists = []string{"copy", "download", "replace", "contentSwap", "overrides"}
+
+// cfgDoc is the resolved config: the final setup nodes and the file each node was declared in.
+type cfgDoc struct {
+	path     string
+	profile  string
+	setups   []*yaml.Node
+	files    map[*yaml.Node]string
+	replaces map[*yaml.Node]bool
+	loading  []string
+	vars     *cfgVars
+	warnings ConfigErrors
+}
+
+func resolveCfg(cfgPath, profile string) (*cfgDoc, error) {
+	d := &cfgDoc{
+		path:     cfgPath,
+		profile:  profile,
+		files:    map[*yaml.Node]string{},
+		replaces: map[*yaml.Node]bool{},
+	}
+
+	vars, err := loadCfgVars(filepath.Dir(cfgPath), profile)
+	if err != nil {
+		return nil, err
+	}
+	d.vars = vars
+
+	_, setups, err := d.resolveFile(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile != "" {
+		// A profile can also consist of a .env.<profile> file only.
+		found := profile == "prod" || slices.Contains(d.vars.files, filepath.Join(filepath.Dir(cfgPath), ".env."+profile))
+
+		for i, setup := range setups {
+			var ok bool
+			setups[i], ok = d.applyProfile(setup)
+			found = found || ok
+		}
+
+		if !found {
+			return nil, fmt.Errorf("profile %q is not defined in %s", profile, cfgPath)
+		}
+	}
+
+	d.setups = setups
+	return d, nil
+}
+
+// applyProfile merges the settings of the selected profile over the setup.
+func (d *cfgDoc) applyProfile(setup *yaml.Node) (*yaml.Node, bool) {
+	var profile *yaml.Node
+
+	if profiles := mappingValue(setup, "profiles"); profiles != nil {
+		profile = mappingValue(profiles, d.profile)
+	}
+
+	found := profile != nil
+
+	if d.profile == "prod" {
+		base := yaml.Node{}
+		yaml.Unmarshal([]byte(prodProfile), &base)
+		profile = d.merge(base.Content[0], profile, "")
+	}
+
+	return d.merge(setup, profile, ""), found
+}
+
+// fileOf returns the file a node was declared in.
+func (d *cfgDoc) fileOf(node *yaml.Node) string {
+	if file, ok := d.files[node]; ok {
+		return file
+	}
+
+	return d.path
+}
+
+// sourceFiles returns all files the config was read from, including the .env files.
+func (d *cfgDoc) sourceFiles() []string {
+	files := append([]string{d.path}, d.vars.files...)
+	for _, file := range d.files {
+		if !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+func (d *cfgDoc) errorAt(node *yaml.Node, path, msg string) error {
+	return ConfigErrors{{File: d.fileOf(node), Line: node.Line, Column: node.Column, Path: path, Msg: msg}}
+}
+
+// resolveFile returns the defaults and the fully merged setups of a config file.
+func (d *cfgDoc) resolveFile(path string) (*yaml.Node, []*yaml.Node, error) {
+	if slices.Contains(d.loading, path) {
+		return nil, nil, fmt.Errorf("config files extend or include each other: %s -> %s", strings.Join(d.loading, " -> "), path)
+	}
+
+	d.loading = append(d.loading, path)
+	defer func() { d.loading = d.loading[:len(d.loading)-1] }()
+
+	root, err := d.loadFile(path)
+	if err != nil || root == nil {
+		return nil, nil, err
+	}
+
+	dir := filepath.Dir(path)
+
+	if root.Kind == yaml.SequenceNode {
+		setups := []*yaml.Node{}
+		for _, node := range root.Content {
+			setup, err := d.resolveSetup(node, dir)
+			if err != nil {
+				return nil, nil, err
+			}
+			setups = append(setups, setup)
+		}
+
+		return nil, setups, nil
+	}
+
+	if !isCfgDocument(root) {
+		setup, err := d.resolveSetup(root, dir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return nil, []*yaml.Node{setup}, nil
+	}
+
+	docFields := yamlFields(reflect.TypeOf(cfgDocument{}))
+	var defaults *yaml.Node
+	setups := []*yaml.Node{}
+	included := []*yaml.Node{}
+
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		key := root.Content[i]
+		if _, ok := docFields[key.Value]; !ok {
+			return nil, nil, d.errorAt(key, key.Value, fmt.Sprintf("unknown key %q, expected one of: extends, include, defaults, setups", key.Value))
+		}
+	}
+
+	if node := mappingValue(root, "defaults"); node != nil {
+		defaults, err = d.resolveSetup(node, dir)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if node := mappingValue(root, "extends"); node != nil {
+		bases, err := d.pathList(node, "extends", dir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		defaults, err = d.extend(bases, defaults)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if node := mappingValue(root, "setups"); node != nil {
+		if node.Kind != yaml.SequenceNode {
+			return nil, nil, d.errorAt(node, "setups", "expected a list of setups")
+		}
+
+		for _, n := range node.Content {
+			setup, err := d.resolveSetup(n, dir)
+			if err != nil {
+				return nil, nil, err
+			}
+			setups = append(setups, d.merge(defaults, setup, ""))
+		}
+	}
+
+	if node := mappingValue(root, "include"); node != nil {
+		files, err := d.pathList(node, "include", dir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, file := range files {
+			_, incSetups, err := d.resolveFile(file)
+			if err != nil {
+				return nil, nil, err
+			}
+			included = append(included, incSetups...)
+		}
+	}
+
+	return defaults, append(setups, included...), nil
+}
+
+// resolveSetup applies the extends key of a setup.
+func (d *cfgDoc) resolveSetup(node *yaml.Node, dir string) (*yaml.Node, error) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil, d.errorAt(node, "", "expected a setup mapping")
+	}
+
+	extendsNode := mappingValue(node, "extends")
+	if extendsNode == nil {
+		return node, nil
+	}
+
+	bases, err := d.pathList(extendsNode, "extends", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the extends key, it's fully handled here.
+	setup := *node
+	setup.Content = []*yaml.Node{}
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value != "extends" {
+			setup.Content = append(setup.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+	d.files[&setup] = d.fileOf(node)
+
+	return d.extend(bases, &setup)
+}
+
+// extend merges node over the given base files. A base file is either a single setup or a document with defaults.
+func (d *cfgDoc) extend(bases []string, node *yaml.Node) (*yaml.Node, error) {
+	var merged *yaml.Node
+
+	for _, base := range bases {
+		defaults, setups, err := d.resolveFile(base)
+		if err != nil {
+			return nil, err
+		}
+
+		if defaults == nil {
+			if len(setups) != 1 {
+				return nil, fmt.Errorf("%s can't be extended, it must contain a single setup or defaults", base)
+			}
+			defaults = setups[0]
+		}
+
+		merged = d.merge(merged, defaults, "")
+	}
+
+	return d.merge(merged, node, ""), nil
+}
+
+// merge returns override merged over base. Nodes are shared, not copied, so their file and position stay intact.
+func (d *cfgDoc) merge(base, override *yaml.Node, key string) *yaml.Node {
+	if base == nil {
+		return override
+	}
+
+	if override == nil {
+		return base
+	}
+
+	if base.Kind == yaml.AliasNode {
+		base = base.Alias
+	}
+
+	if override.Kind == yaml.AliasNode {
+		override = override.Alias
+	}
+
+	if d.replaces[override] {
+		return override
+	}
+
+	if base.Kind == yaml.MappingNode && override.Kind == yaml.MappingNode {
+		merged := *override
+		merged.Content = []*yaml.Node{}
+		d.files[&merged] = d.fileOf(override)
+
+		for i := 0; i < len(base.Content)-1; i += 2 {
+			k := base.Content[i]
+			v := base.Content[i+1]
+
+			if ov := mappingValue(override, k.Value); ov != nil {
+				v = d.merge(v, ov, k.Value)
+			}
+
+			merged.Content = append(merged.Content, k, v)
+		}
+
+		for i := 0; i < len(override.Content)-1; i += 2 {
+			if mappingValue(base, override.Content[i].Value) == nil {
+				merged.Content = append(merged.Content, override.Content[i], override.Content[i+1])
+			}
+		}
+
+		return &merged
+	}
+
+	if base.Kind == yaml.SequenceNode && override.Kind == yaml.SequenceNode && slices.Contains(appendedLists, key) {
+		merged := *override
+		merged.Content = append(append([]*yaml.Node{}, base.Content...), override.Content...)
+		d.files[&merged] = d.fileOf(override)
+		return &merged
+	}
+
+	return override
+}
+
+// loadFile parses a config file, remembers which file its nodes belong to, expands variables and makes relative paths absolute.
+func (d *cfgDoc) loadFile(path string) (*yaml.Node, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+
+	// JSON configs are read like YAML, but their keys are the capitalized names of the Options fields.
+	if filepath.Ext(path) == ".json" {
+		normalizeCfgKeys(root)
+	}
+
+	d.register(root, path)
+
+	errs := ConfigErrors{}
+	d.interpolate(root, "", &errs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	dir := filepath.Dir(path)
+	setupType := reflect.TypeOf(Options{})
+
+	switch {
+	case root.Kind == yaml.SequenceNode:
+		for _, node := range root.Content {
+			rebasePaths(node, setupType, dir)
+		}
+	case isCfgDocument(root):
+		rebasePaths(root, reflect.TypeOf(cfgDocument{}), dir)
+	default:
+		rebasePaths(root, setupType, dir)
+	}
+
+	return root, nil
+}
+
+func (d *cfgDoc) register(node *yaml.Node, path string) {
+	d.files[node] = path
+
+	if node.Tag == "!replace" {
+		d.replaces[node] = true
+		node.Tag = ""
+	}
+
+	for _, child := range node.Content {
+		d.register(child, path)
+	}
+}
+
+func (d *cfgDoc) pathList(node *yaml.Node, key, dir string) ([]string, error) {
+	list := StringList{}
+	if err := node.Decode(&list); err != nil {
+		return nil, d.errorAt(node, key, decodeErrMsg(err))
+	}
+
+	for i, p := range list {
+		if !filepath.IsAbs(p) {
+			list[i] = filepath.Join(dir, p)
+		}
+	}
+
+	return list, nil
+}
+
+func isCfgDocument(node *yaml.Node) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+
+	return mappingValue(node, "setups") != nil || mappingValue(node, "defaults") != nil || mappingValue(node, "include") != nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// rebasePaths makes the relative values of all fields tagged with `path:"true"` absolute, based on dir.
+// Paths starting with ~ are left alone.
+func rebasePaths(node *yaml.Node, t reflect.Type, dir string) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+
+		for i := 0; i < len(node.Content)-1; i += 2 {
+			field, ok := fields[node.Content[i].Value]
+			if !ok {
+				continue
+			}
+
+			value := node.Content[i+1]
+			if field.Tag.Get("path") == "true" {
+				rebasePath(value, dir)
+				for _, item := range value.Content {
+					rebasePath(item, dir)
+				}
+				continue
+			}
+
+			rebasePaths(value, field.Type, dir)
+		}
+
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, item := range node.Content {
+			rebasePaths(item, t.Elem(), dir)
+		}
+
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 1; i < len(node.Content); i += 2 {
+			rebasePaths(node.Content[i], t.Elem(), dir)
+		}
+	}
+}
+
+func rebasePath(node *yaml.Node, dir string) {
+	if node.Kind != yaml.ScalarNode || node.ShortTag() == "!!null" || node.Value == "" {
+		return
+	}
+
+	if filepath.IsAbs(node.Value) || strings.HasPrefix(node.Value, "~") {
+		return
+	}
+
+	node.Value = filepath.Join(dir, node.Value)
+}
@@ -0,0 +1,146 @@
+package gowebbuild
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigMerge(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		profile string
+		// want summarizes every setup: name, minify, bundle, port and the copy sources.
+		want []string
+	}{
+		{
+			name: "defaults under setups",
+			files: map[string]string{"gowebbuild.yaml": `
+defaults:
+  esbuild: {bundle: true, minify: true}
+setups:
+  - name: app
+    esbuild: {minify: false}
+  - name: admin
+`},
+			want: []string{"app minify=false bundle=true port=0 copy=[]", "admin minify=true bundle=true port=0 copy=[]"},
+		},
+		{
+			name: "rule lists are appended",
+			files: map[string]string{
+				"base.yaml": "copy: [{src: a, dest: out}]\nserve: {port: 3000}\n",
+				"gowebbuild.yaml": `
+extends: base.yaml
+copy: [{src: b, dest: out}]
+serve: {port: 4000}
+`,
+			},
+			want: []string{" minify=false bundle=false port=4000 copy=[a b]"},
+		},
+		{
+			name: "!replace replaces an inherited rule list",
+			files: map[string]string{
+				"base.yaml": "copy: [{src: a, dest: out}]\n",
+				"gowebbuild.yaml": `
+extends: base.yaml
+copy: !replace [{src: b, dest: out}]
+`,
+			},
+			want: []string{" minify=false bundle=false port=0 copy=[b]"},
+		},
+		{
+			name: "!replace replaces an inherited mapping",
+			files: map[string]string{
+				"base.yaml": "esbuild: {bundle: true, minify: true}\n",
+				"gowebbuild.yaml": `
+extends: base.yaml
+esbuild: !replace {minify: true}
+`,
+			},
+			want: []string{" minify=true bundle=false port=0 copy=[]"},
+		},
+		{
+			name: "extends chain and defaults of a document",
+			files: map[string]string{
+				"root.yaml": "esbuild: {bundle: true}\ncopy: [{src: a, dest: out}]\n",
+				"base.yaml": "extends: root.yaml\ndefaults:\n  copy: [{src: b, dest: out}]\n",
+				"gowebbuild.yaml": `
+extends: base.yaml
+setups:
+  - name: app
+    copy: [{src: c, dest: out}]
+`,
+			},
+			want: []string{"app minify=false bundle=true port=0 copy=[a b c]"},
+		},
+		{
+			name: "profile over the setup",
+			files: map[string]string{"gowebbuild.yaml": `
+name: app
+copy: [{src: a, dest: out}]
+profiles:
+  staging:
+    esbuild: {minify: true}
+    copy: [{src: b, dest: out}]
+`},
+			profile: "staging",
+			want:    []string{"app minify=true bundle=false port=0 copy=[a b]"},
+		},
+		{
+			name:    "prod profile",
+			files:   map[string]string{"gowebbuild.yaml": "name: app\nesbuild: {bundle: true}\n"},
+			profile: "prod",
+			want:    []string{"app minify=true bundle=true port=0 copy=[]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := LoadConfig(filepath.Join(dir, "gowebbuild.yaml"), WithProfile(tt.profile))
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			got := []string{}
+			for _, opts := range cfg.Setups {
+				srcs := []string{}
+				for _, op := range opts.Copy {
+					src, _ := filepath.Rel(dir, op.Src)
+					srcs = append(srcs, src)
+				}
+
+				got = append(got, fmt.Sprintf("%s minify=%t bundle=%t port=%d copy=[%s]",
+					opts.Name, opts.ESBuild.Minify, opts.ESBuild.Bundle, opts.Serve.Port, strings.Join(srcs, " ")))
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("setups:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestLoadConfigPathError(t *testing.T) {
+	// Without a home folder ~ can't be expanded.
+	t.Setenv("HOME", "")
+
+	path := filepath.Join(t.TempDir(), "gowebbuild.yaml")
+	if err := os.WriteFile(path, []byte("esbuild:\n  outdir: ~/dist\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "$HOME") {
+		t.Errorf("LoadConfig() error = %v, want an error about $HOME", err)
+	}
+}
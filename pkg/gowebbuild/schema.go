@@ -0,0 +1,206 @@
+package gowebbuild
+
+import "reflect"
+
+// schemaProvider is implemented by config types with custom decoding, whose schema can't be derived from their Go type.
+type schemaProvider interface {
+	jsonSchema() map[string]any
+}
+
+var schemaProviderType = reflect.TypeOf((*schemaProvider)(nil)).Elem()
+
+// Schema generates a JSON schema of the config file from the Options struct,
+// so it accepts exactly what LoadConfig accepts.
+func Schema() map[string]any {
+	defs := map[string]any{}
+	setup := typeSchema(reflect.TypeOf(Options{}), defs)
+	document := typeSchema(reflect.TypeOf(cfgDocument{}), defs)
+
+	return map[string]any{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "gowebbuild config",
+		"description": "A single setup, a list of setups or a document with shared defaults and setups that gowebbuild builds and watches.",
+		"anyOf": []any{
+			document,
+			setup,
+			map[string]any{
+				"type":  "array",
+				"items": setup,
+			},
+		},
+		"definitions": defs,
+	}
+}
+
+func typeSchema(t reflect.Type, defs map[string]any) map[string]any {
+	if t.Implements(schemaProviderType) {
+		return reflect.Zero(t).Interface().(schemaProvider).jsonSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), defs)
+
+	case reflect.Struct:
+		// Named structs are shared through definitions, anonymous ones are inlined.
+		if t.Name() == "" {
+			return structSchema(t, defs)
+		}
+
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil
+			defs[t.Name()] = structSchema(t, defs)
+		}
+
+		return map[string]any{"$ref": "#/definitions/" + t.Name()}
+
+	case reflect.Slice:
+		return map[string]any{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), defs),
+		}
+
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), defs),
+		}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Bool:
+		return orVariable(map[string]any{"type": "boolean"})
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return orVariable(map[string]any{"type": "integer"})
+
+	case reflect.Float32, reflect.Float64:
+		return orVariable(map[string]any{"type": "number"})
+	}
+
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	props := map[string]any{}
+
+	for name, field := range yamlFields(t) {
+		prop := typeSchema(field.Type, defs)
+
+		if desc := field.Tag.Get("desc"); desc != "" {
+			// Don't modify schemas that are shared through definitions.
+			if _, ok := prop["$ref"]; ok {
+				prop = map[string]any{"allOf": []any{prop}}
+			}
+			prop["description"] = desc
+		}
+
+		props[name] = prop
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
+
+// variableSchema matches a string with a variable reference like ${PORT}. The value is only typed after the variable
+// is expanded, so `port: ${PORT}` is a string to the editor but an integer to LoadConfig.
+var variableSchema = map[string]any{
+	"type":    "string",
+	"pattern": `\$(\{[^}]+\}|[A-Za-z_])`,
+}
+
+// orVariable allows a variable reference in place of a value that isn't a string.
+func orVariable(schema map[string]any) map[string]any {
+	return map[string]any{"anyOf": []any{schema, variableSchema}}
+}
+
+func enumSchema[T comparable](names map[string]T) map[string]any {
+	return map[string]any{
+		"type": "string",
+		"enum": sortedEnumNames(names),
+	}
+}
+
+// legacyEnumSchema also allows the integer values that older configs used.
+func legacyEnumSchema[T comparable](names map[string]T) map[string]any {
+	return map[string]any{
+		"anyOf": []any{
+			enumSchema(names),
+			map[string]any{"type": "integer", "description": "Deprecated: raw esbuild enum value."},
+		},
+	}
+}
+
+func (Format) jsonSchema() map[string]any {
+	return legacyEnumSchema(formatNames)
+}
+
+func (Platform) jsonSchema() map[string]any {
+	return legacyEnumSchema(platformNames)
+}
+
+func (LogLevel) jsonSchema() map[string]any {
+	return legacyEnumSchema(logLevelNames)
+}
+
+func (SourceMap) jsonSchema() map[string]any {
+	s := legacyEnumSchema(sourceMapNames)
+	s["anyOf"] = append(s["anyOf"].([]any), map[string]any{"type": "boolean"})
+	return s
+}
+
+func (Target) jsonSchema() map[string]any {
+	target := map[string]any{
+		"type":     "string",
+		"examples": append(sortedEnumNames(targetNames), "chrome100", "firefox110", "safari15.4", "node20"),
+	}
+
+	return map[string]any{
+		"anyOf": []any{
+			target,
+			map[string]any{"type": "array", "items": target},
+			map[string]any{"type": "integer", "description": "Deprecated: raw esbuild enum value."},
+		},
+	}
+}
+
+func (Loader) jsonSchema() map[string]any {
+	return enumSchema(loaderNames)
+}
+
+func (LegalComments) jsonSchema() map[string]any {
+	return enumSchema(legalCommentsNames)
+}
+
+func (JSX) jsonSchema() map[string]any {
+	return enumSchema(jsxNames)
+}
+
+func (Charset) jsonSchema() map[string]any {
+	return enumSchema(charsetNames)
+}
+
+func (Packages) jsonSchema() map[string]any {
+	return enumSchema(packagesNames)
+}
+
+func (Drop) jsonSchema() map[string]any {
+	return map[string]any{
+		"type":  "array",
+		"items": enumSchema(dropNames),
+	}
+}
+
+func (StringList) jsonSchema() map[string]any {
+	return map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	}
+}
@@ -0,0 +1,430 @@
+package gowebbuild
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/otiai10/copy"
+	"github.com/trading-peter/gowebbuild/fsutils"
+)
+
+func (p *Pipeline) purge() error {
+	opts := p.Options
+
+	if opts.ESBuild.PurgeBeforeBuild {
+		if opts.ESBuild.Outdir != "" {
+			p.emit(Event{Type: EventFilePurged, Stage: StagePurge, Path: opts.ESBuild.Outdir, Message: fmt.Sprintf("Purging output folder %s", opts.ESBuild.Outdir)})
+			if err := os.RemoveAll(opts.ESBuild.Outdir); err != nil {
+				return err
+			}
+		}
+
+		if opts.ESBuild.Outfile != "" {
+			p.emit(Event{Type: EventFilePurged, Stage: StagePurge, Path: opts.ESBuild.Outfile, Message: fmt.Sprintf("Purging output file %s", opts.ESBuild.Outfile)})
+			if err := os.Remove(opts.ESBuild.Outfile); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// download fetches the configured files. Failed downloads are reported as warnings and don't stop the others.
+func (p *Pipeline) download(ctx context.Context) error {
+	for _, dl := range p.Options.Download {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !fsutils.IsDir(filepath.Dir(dl.Dest)) {
+			p.warn(StageDownload, fmt.Sprintf("Failed to find destination folder for downloading from %s", dl.Url), nil)
+			continue
+		}
+
+		p.info(StageDownload, fmt.Sprintf("Downloading %s to %s", dl.Url, dl.Dest))
+		if err := downloadFile(ctx, dl.Url, dl.Dest); err != nil {
+			p.warn(StageDownload, fmt.Sprintf("Failed to download file from %s: %v", dl.Url, err), err)
+			continue
+		}
+
+		p.emit(Event{Type: EventFileDownloaded, Stage: StageDownload, Src: dl.Url, Dest: dl.Dest})
+	}
+
+	return nil
+}
+
+func downloadFile(ctx context.Context, url, dest string) error {
+	client := http.Client{
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			r.URL.Opaque = r.URL.Path
+			return nil
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, resp.Body)
+	return err
+}
+
+// copy runs the copy rules. Rules that fail are reported as warnings and don't stop the others.
+func (p *Pipeline) copy(ctx context.Context) error {
+	if len(p.Options.Copy) == 0 {
+		p.info(StageCopy, "Nothing to copy")
+		return nil
+	}
+
+	for i, op := range p.Options.Copy {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if p.plan != nil && p.plan.copyRules != nil && !p.plan.copyRules[i] {
+			continue
+		}
+
+		paths, err := filepath.Glob(op.Src)
+		if err != nil {
+			p.warn(StageCopy, fmt.Sprintf("Invalid glob pattern: %s", op.Src), err)
+			continue
+		}
+
+		destIsDir := fsutils.IsDir(op.Dest)
+		for _, src := range paths {
+			d := op.Dest
+
+			if destIsDir && fsutils.IsFile(src) {
+				d = filepath.Join(d, filepath.Base(src))
+			}
+
+			err := copy.Copy(src, d)
+			p.emit(Event{Type: EventFileCopied, Stage: StageCopy, Src: src, Dest: d, Message: fmt.Sprintf("Copying %s to %s", src, d)})
+
+			if err != nil {
+				p.warn(StageCopy, fmt.Sprintf("Failed to copy %s: %v", src, err), err)
+				continue
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Pipeline) esbuild(ctx context.Context) (*api.BuildResult, error) {
+	buildOptions := ESBuildOptions(p.Options)
+	buildOptions.Plugins = append(buildOptions.Plugins, contentSwapPlugin(p.Options, p.info))
+	buildOptions.Plugins = append(buildOptions.Plugins, p.plugins...)
+
+	pages, err := p.htmlPages(&buildOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	p.stateMu.Lock()
+	p.pages = pages
+	p.stateMu.Unlock()
+	// The inputs of the build decide which changes need a rebuild, see RunChanges.
+	buildOptions.Metafile = true
+
+	var result api.BuildResult
+	if p.incremental {
+		result = p.rebuild(ctx, buildOptions)
+	} else {
+		result = api.Build(buildOptions)
+	}
+
+	// A canceled build reports an error for every entry point, the cancellation is the only one worth reporting.
+	if err := ctx.Err(); err != nil {
+		return &result, err
+	}
+
+	if len(result.Errors) > 0 {
+		return &result, &BuildError{Errors: result.Errors, Warnings: result.Warnings}
+	}
+
+	if len(pages) > 0 && buildOptions.Write {
+		if err := p.writePages(pages, &result); err != nil {
+			return &result, err
+		}
+	}
+
+	metafile := p.Options.Metafile
+	if metafile == "" && p.Options.ESBuild.Metafile {
+		metafile = MetafilePath(p.Options)
+	}
+
+	if metafile != "" {
+		if err := os.MkdirAll(filepath.Dir(metafile), 0755); err != nil {
+			return &result, err
+		}
+
+		if err := os.WriteFile(metafile, []byte(result.Metafile), 0644); err != nil {
+			return &result, err
+		}
+
+		p.emit(Event{Type: EventFileWritten, Stage: StageESBuild, Path: metafile, Message: fmt.Sprintf("Wrote metafile %s", metafile)})
+	}
+
+	return &result, nil
+}
+
+func contentSwapPlugin(opts Options, info func(Stage, string)) api.Plugin {
+	return api.Plugin{
+		Name: "content-swap",
+		Setup: func(build api.PluginBuild) {
+			build.OnLoad(api.OnLoadOptions{Filter: `.*`},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					for _, swap := range opts.ContentSwap {
+						if strings.HasSuffix(args.Path, swap.File) {
+							info(StageESBuild, fmt.Sprintf("Swapping content of %s with %s", args.Path, swap.ReplaceWith))
+
+							text, err := os.ReadFile(swap.ReplaceWith)
+							if err != nil {
+								return api.OnLoadResult{}, err
+							}
+							contents := string(text)
+							return api.OnLoadResult{
+								Contents: &contents,
+								Loader:   api.LoaderJS,
+							}, nil
+						}
+					}
+					return api.OnLoadResult{}, nil
+				})
+		},
+	}
+}
+
+func (p *Pipeline) replace(ctx context.Context) error {
+	if len(p.Options.Replace) == 0 {
+		p.info(StageReplace, "Nothing to replace")
+		return nil
+	}
+
+	for i, op := range p.Options.Replace {
+		if p.plan != nil && p.plan.replaceRules != nil && !p.plan.replaceRules[i] {
+			continue
+		}
+
+		paths, err := filepath.Glob(op.Pattern)
+		if err != nil {
+			p.warn(StageReplace, fmt.Sprintf("Invalid glob pattern: %s", op.Pattern), err)
+			continue
+		}
+
+		for _, path := range paths {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if !fsutils.IsFile(path) {
+				continue
+			}
+
+			read, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			count := strings.Count(string(read), op.Search)
+
+			if count > 0 {
+				p.emit(Event{
+					Type:    EventFileReplaced,
+					Stage:   StageReplace,
+					Path:    path,
+					Count:   count,
+					Message: fmt.Sprintf("Replacing %d occurrences of '%s' with '%s' in %s", count, op.Search, op.Replace, path),
+				})
+
+				newContents := strings.ReplaceAll(string(read), op.Search, op.Replace)
+				if err := os.WriteFile(path, []byte(newContents), 0); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Pipeline) postBuild(ctx context.Context) error {
+	command := p.Options.ProductionBuildOptions.CmdPostBuild
+	if command == "" {
+		return nil
+	}
+
+	p.info(StagePostBuild, fmt.Sprintf("Executing post production build command `%s`", command))
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = p.cmdOut
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return &CommandError{Command: command, Err: err}
+	}
+
+	return nil
+}
+
+func (p *Pipeline) injectLiveReload() error {
+	opts := p.Options
+	if opts.Watch.InjectLiveReload == "" {
+		return nil
+	}
+
+	// Problems are only reported as warnings, the page just won't reload by itself then.
+	// Read the HTML file
+	contents, err := os.ReadFile(opts.Watch.InjectLiveReload)
+	if err != nil {
+		p.warn(StageInjectLiveReload, fmt.Sprintf("Failed to read inject live reload script: %v", err), err)
+		return nil
+	}
+
+	htmlContent := string(contents)
+
+	if !opts.Watch.SkipCSPInject {
+		// First modify CSP
+		htmlContent, err = updateContentPolicyTag(p.LiveReloadPort, htmlContent)
+		if err != nil {
+			p.warn(StageInjectLiveReload, fmt.Sprintf("Error modifying CSP: %v", err), err)
+			return nil
+		}
+	}
+
+	// Then inject script
+	finalHTML, err := injectLiveReloadScript(p.LiveReloadPort, htmlContent)
+	if err != nil {
+		p.warn(StageInjectLiveReload, fmt.Sprintf("Error injecting script: %v", err), err)
+		return nil
+	}
+
+	err = os.WriteFile(opts.Watch.InjectLiveReload, []byte(finalHTML), 0644)
+	if err != nil {
+		p.warn(StageInjectLiveReload, fmt.Sprintf("Failed to write live reload script reference: %v", err), err)
+		return nil
+	}
+
+	p.emit(Event{
+		Type:    EventFileWritten,
+		Stage:   StageInjectLiveReload,
+		Path:    opts.Watch.InjectLiveReload,
+		Message: fmt.Sprintf("Injected live reload script reference into %s", opts.Watch.InjectLiveReload),
+	})
+	return nil
+}
+
+func injectLiveReloadScript(lrport uint, html string) (string, error) {
+	// Check if script is already present
+	if strings.Contains(html, "livereload.js") {
+		return html, nil
+	}
+
+	// Find the closing body tag and inject script before it
+	bodyCloseRegex := regexp.MustCompile(`(?i)</body>`)
+	if !bodyCloseRegex.MatchString(html) {
+		return html, nil // Return unchanged if no body tag found
+	}
+
+	scriptTag := fmt.Sprintf(`<script src="http://localhost:%d/livereload.js" type="text/javascript"></script>`, lrport)
+	newHTML := bodyCloseRegex.ReplaceAllString(html, scriptTag+"</body>")
+
+	return newHTML, nil
+}
+
+func updateContentPolicyTag(lrport uint, html string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return html, err
+	}
+
+	liveReloadHost := fmt.Sprintf("localhost:%d", lrport)
+	liveReloadURL := "http://" + liveReloadHost
+	liveReloadWS := "ws://" + liveReloadHost
+
+	doc.Find("meta[http-equiv='Content-Security-Policy']").Each(func(i int, s *goquery.Selection) {
+		if originalCSP, ok := s.Attr("content"); ok {
+			// Split CSP into individual directives
+			directives := strings.Split(originalCSP, ";")
+
+			// Look for script-src directive
+			scriptSrcFound := false
+			connectSrcFound := false
+
+			for i, directive := range directives {
+				trimmed := strings.TrimSpace(directive)
+
+				// Handle script-src directive
+				if strings.HasPrefix(trimmed, "script-src") {
+					// If script-src already exists, append localhost if not present
+					if !strings.Contains(trimmed, liveReloadURL) {
+						directives[i] = trimmed + " " + liveReloadURL
+					}
+					scriptSrcFound = true
+				}
+
+				// Handle connect-src directive
+				if strings.HasPrefix(trimmed, "connect-src") {
+					// If connect-src already exists, append WebSocket URL if not present
+					if !strings.Contains(trimmed, liveReloadWS) {
+						directives[i] = trimmed + " " + liveReloadWS
+					}
+					connectSrcFound = true
+				}
+			}
+
+			// If no script-src found, add it with 'self' as default
+			if !scriptSrcFound {
+				directives = append(directives, "script-src 'self' "+liveReloadURL)
+			}
+
+			// If no connect-src found, add it with 'self' as default
+			if !connectSrcFound {
+				directives = append(directives, "connect-src 'self' "+liveReloadWS)
+			}
+
+			// Join directives back together
+			newCSP := strings.Join(directives, ";")
+
+			// Ensure we don't have trailing semicolon if original didn't
+			if !strings.HasSuffix(originalCSP, ";") && strings.HasSuffix(newCSP, ";") {
+				newCSP = strings.TrimSuffix(newCSP, ";")
+			}
+
+			s.SetAttr("content", newCSP)
+		}
+	})
+
+	var buf bytes.Buffer
+	err = goquery.Render(&buf, doc.Selection)
+	if err != nil {
+		return html, err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,405 @@
+package gowebbuild
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/trading-peter/gowebbuild/fsutils"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError is a problem in a config file at the given position.
+// Path is the location of the value in the config, e.g. [0].esbuild.outdir.
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Path   string
+	Msg    string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.File, e.Line, e.Column, e.Path, e.Msg)
+}
+
+// ConfigErrors are all problems found while loading a config.
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+
+	return fmt.Sprintf("found %d problem(s) in config:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+var yamlLinePrefix = regexp.MustCompile(`^line \d+: `)
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// checkCfgSchema walks the yaml nodes of all setups and compares them against the Options struct.
+// Unlike decoding, it reports every unknown key and type mismatch instead of stopping at (or ignoring) the first one.
+func checkCfgSchema(doc *cfgDoc) ConfigErrors {
+	errs := ConfigErrors{}
+
+	for i, node := range doc.setups {
+		checkNode(doc, node, reflect.TypeOf(Options{}), setupPath(i, len(doc.setups)), &errs)
+	}
+
+	// Setups that inherit from the same defaults share their nodes, report each problem only once.
+	seen := map[string]bool{}
+	unique := ConfigErrors{}
+	for _, err := range errs {
+		pos := fmt.Sprintf("%s:%d:%d", err.File, err.Line, err.Column)
+		if !seen[pos] {
+			seen[pos] = true
+			unique = append(unique, err)
+		}
+	}
+
+	return unique
+}
+
+func checkNode(doc *cfgDoc, node *yaml.Node, t reflect.Type, path string, errs *ConfigErrors) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	addErr := func(n *yaml.Node, msg string) {
+		*errs = append(*errs, ConfigError{File: doc.fileOf(n), Line: n.Line, Column: n.Column, Path: path, Msg: msg})
+	}
+
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+		return
+	}
+
+	// Types with custom decoding (and all leaf values) are checked by letting yaml decode them.
+	if reflect.PointerTo(t).Implements(unmarshalerType) || isLeafKind(t.Kind()) {
+		if err := node.Decode(reflect.New(t).Interface()); err != nil {
+			addErr(node, decodeErrMsg(err))
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		checkNode(doc, node, t.Elem(), path, errs)
+
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			addErr(node, fmt.Sprintf("expected a mapping, got %s", nodeKindName(node)))
+			return
+		}
+
+		fields := yamlFields(t)
+
+		for i := 0; i < len(node.Content)-1; i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q", key.Value)
+				for name := range fields {
+					if strings.EqualFold(name, key.Value) {
+						msg += fmt.Sprintf(", did you mean %q?", name)
+					}
+				}
+
+				*errs = append(*errs, ConfigError{File: doc.fileOf(key), Line: key.Line, Column: key.Column, Path: joinCfgPath(path, key.Value), Msg: msg})
+				continue
+			}
+
+			checkNode(doc, value, field.Type, joinCfgPath(path, key.Value), errs)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			addErr(node, fmt.Sprintf("expected a list, got %s", nodeKindName(node)))
+			return
+		}
+
+		for i, item := range node.Content {
+			checkNode(doc, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			addErr(node, fmt.Sprintf("expected a mapping, got %s", nodeKindName(node)))
+			return
+		}
+
+		for i := 0; i < len(node.Content)-1; i += 2 {
+			checkNode(doc, node.Content[i+1], t.Elem(), fmt.Sprintf("%s[%q]", path, node.Content[i].Value), errs)
+		}
+	}
+}
+
+// checkCfgSetups looks for mistakes that are valid yaml, but won't build: missing entry points or copy sources and
+// conflicting output settings.
+func checkCfgSetups(doc *cfgDoc, setups []Options) ConfigErrors {
+	errs := ConfigErrors{}
+	names := map[string]bool{}
+
+	for i, opts := range setups {
+		node := doc.setups[i]
+		prefix := setupPath(i, len(setups))
+
+		addErr := func(msg string, keys ...any) {
+			n := cfgNode(node, keys...)
+			errs = append(errs, ConfigError{File: doc.fileOf(n), Line: n.Line, Column: n.Column, Path: joinCfgPath(prefix, keysPath(keys)), Msg: msg})
+		}
+
+		if opts.Name != "" {
+			if names[opts.Name] {
+				addErr(fmt.Sprintf("setup name %q is used more than once", opts.Name), "name")
+			}
+			names[opts.Name] = true
+		}
+
+		// Downloads may create entry points, so they don't have to exist yet.
+		downloads := map[string]bool{}
+		for _, dl := range opts.Download {
+			downloads[dl.Dest] = true
+		}
+
+		for j, entry := range opts.ESBuild.EntryPoints {
+			if !downloads[entry] && !pathExists(entry) {
+				addErr(fmt.Sprintf("entry point %s does not exist", entry), "esbuild", "entryPoints", j)
+			}
+		}
+
+		for j, entry := range opts.ESBuild.EntryPointsAdvanced {
+			if !downloads[entry.In] && !pathExists(entry.In) {
+				addErr(fmt.Sprintf("entry point %s does not exist", entry.In), "esbuild", "entryPointsAdvanced", j, "in")
+			}
+
+			if isHTMLEntryPoint(entry.In) {
+				addErr("HTML pages can only be used in esbuild.entryPoints", "esbuild", "entryPointsAdvanced", j, "in")
+			}
+		}
+
+		if opts.ESBuild.Outdir == "" && slices.ContainsFunc(opts.ESBuild.EntryPoints, isHTMLEntryPoint) {
+			addErr("HTML entry points require esbuild.outdir", "esbuild", "entryPoints")
+		}
+
+		for j, p := range opts.Watch.Paths {
+			if !pathExists(p) {
+				addErr(fmt.Sprintf("watch path %s does not exist", p), "watch", "paths", j)
+			}
+		}
+
+		for j, c := range opts.Copy {
+			if !pathExists(c.Src) {
+				addErr(fmt.Sprintf("copy source %s does not exist", c.Src), "copy", j, "src")
+			}
+		}
+
+		for j, swap := range opts.ContentSwap {
+			if !fsutils.IsFile(swap.ReplaceWith) {
+				addErr(fmt.Sprintf("file %s does not exist", swap.ReplaceWith), "contentSwap", j, "replaceWith")
+			}
+		}
+
+		for j, b := range opts.Budgets.EntryPoints {
+			found := slices.Contains(opts.ESBuild.EntryPoints, b.EntryPoint)
+			for _, entry := range opts.ESBuild.EntryPointsAdvanced {
+				found = found || entry.In == b.EntryPoint
+			}
+
+			if !found {
+				addErr(fmt.Sprintf("%s is not an entry point of the setup", b.EntryPoint), "budgets", "entryPoints", j, "entryPoint")
+			}
+		}
+
+		entryCount := len(opts.ESBuild.EntryPoints) + len(opts.ESBuild.EntryPointsAdvanced)
+
+		if opts.ESBuild.Outdir != "" && opts.ESBuild.Outfile != "" {
+			addErr("outfile and outdir can't be used together", "esbuild", "outfile")
+		} else if opts.ESBuild.Outfile != "" && entryCount > 1 {
+			addErr("outfile can only be used with a single entry point, use outdir instead", "esbuild", "outfile")
+		} else if opts.ESBuild.Outdir == "" && opts.ESBuild.Write && entryCount > 1 {
+			addErr("outdir is required when building multiple entry points", "esbuild")
+		}
+
+		if opts.Compress.GzipLevel < 0 || opts.Compress.GzipLevel > 9 {
+			addErr("gzipLevel must be between 1 and 9, or 0 for the default (9)", "compress", "gzipLevel")
+		}
+
+		if opts.Compress.BrotliLevel < 0 || opts.Compress.BrotliLevel > 11 {
+			addErr("brotliLevel must be between 1 and 11, or 0 for the default (11)", "compress", "brotliLevel")
+		}
+
+		if opts.ESBuild.Outdir == "" && opts.HashNames {
+			addErr("hashNames requires esbuild.outdir", "hashNames")
+		} else if opts.ESBuild.Outdir == "" && opts.Manifest != "" {
+			addErr("manifest requires esbuild.outdir", "manifest")
+		}
+	}
+
+	errs = append(errs, checkCfgDeps(doc, setups)...)
+	return errs
+}
+
+// checkCfgDeps checks that dependsOn only names existing setups and that setups don't depend on each other.
+func checkCfgDeps(doc *cfgDoc, setups []Options) ConfigErrors {
+	errs := ConfigErrors{}
+	index := map[string]int{}
+	for i, opts := range setups {
+		if opts.Name != "" {
+			index[opts.Name] = i
+		}
+	}
+
+	deps := make([][]int, len(setups))
+	for i, opts := range setups {
+		for j, name := range opts.DependsOn {
+			d, ok := index[name]
+			if !ok {
+				n := cfgNode(doc.setups[i], "dependsOn", j)
+				errs = append(errs, ConfigError{File: doc.fileOf(n), Line: n.Line, Column: n.Column, Path: joinCfgPath(setupPath(i, len(setups)), fmt.Sprintf("dependsOn[%d]", j)), Msg: fmt.Sprintf("no setup named %q found in config", name)})
+				continue
+			}
+			deps[i] = append(deps[i], d)
+		}
+	}
+
+	if cycle := findCycle(deps); cycle != nil {
+		names := []string{}
+		for _, i := range cycle {
+			names = append(names, SetupName(setups[i], i))
+		}
+
+		i := cycle[0]
+		n := cfgNode(doc.setups[i], "dependsOn")
+		errs = append(errs, ConfigError{File: doc.fileOf(n), Line: n.Line, Column: n.Column, Path: joinCfgPath(setupPath(i, len(setups)), "dependsOn"), Msg: (&CycleError{Cycle: names}).Error()})
+	}
+
+	return errs
+}
+
+// cfgNode returns the node at the given path of mapping keys and list indexes.
+// If the path doesn't exist, the deepest existing node is returned, so errors still point close to the problem.
+func cfgNode(node *yaml.Node, keys ...any) *yaml.Node {
+	for _, key := range keys {
+		var next *yaml.Node
+
+		switch k := key.(type) {
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for i := 0; i < len(node.Content)-1; i += 2 {
+					if node.Content[i].Value == k {
+						next = node.Content[i+1]
+					}
+				}
+			}
+		case int:
+			if node.Kind == yaml.SequenceNode && k < len(node.Content) {
+				next = node.Content[k]
+			}
+		}
+
+		if next == nil {
+			return node
+		}
+		node = next
+	}
+
+	return node
+}
+
+func keysPath(keys []any) string {
+	path := ""
+	for _, key := range keys {
+		switch k := key.(type) {
+		case string:
+			path = joinCfgPath(path, k)
+		case int:
+			path += fmt.Sprintf("[%d]", k)
+		}
+	}
+
+	return path
+}
+
+func setupPath(i, count int) string {
+	if count > 1 {
+		return fmt.Sprintf("[%d]", i)
+	}
+
+	return ""
+}
+
+func joinCfgPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// yamlFields maps the yaml key of each field to the field, using the same naming rules as the yaml package.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		fields[name] = f
+	}
+
+	return fields
+}
+
+func isLeafKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64,
+		reflect.Interface:
+		return true
+	}
+
+	return false
+}
+
+func nodeKindName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+func decodeErrMsg(err error) string {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		return yamlLinePrefix.ReplaceAllString(typeErr.Errors[0], "")
+	}
+
+	return yamlLinePrefix.ReplaceAllString(err.Error(), "")
+}
+
+func pathExists(pattern string) bool {
+	matches, err := filepath.Glob(pattern)
+	return err == nil && len(matches) > 0
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
+)
+
+// buildReport collects what happened in every setup and stage of a build from the pipeline events.
+// It's printed as a summary at the end of the build and written as JSON with --report json.
+type buildReport struct {
+	mu     sync.Mutex
+	start  time.Time
+	setups map[string]*setupReport
+	// dir is the folder of the config file, output paths are shown relative to it.
+	dir string
+	// sizes has the output file sizes of the previous build, for the deltas of the budgets stage.
+	sizes sizeState
+}
+
+type jsonReport struct {
+	Status     string         `json:"status"`
+	ExitCode   int            `json:"exitCode"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"durationMs"`
+	Setups     []*setupReport `json:"setups"`
+}
+
+type setupReport struct {
+	Name       string         `json:"name"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"durationMs"`
+	Stages     []*stageReport `json:"stages"`
+}
+
+type stageReport struct {
+	Stage      gowebbuild.Stage `json:"stage"`
+	Status     string           `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	DurationMs int64            `json:"durationMs"`
+	Errors     []reportMessage  `json:"errors,omitempty"`
+	Warnings   []reportMessage  `json:"warnings,omitempty"`
+	Outputs    []reportFile     `json:"outputs,omitempty"`
+	Purged     []string         `json:"purged,omitempty"`
+	Downloaded []reportCopy     `json:"downloaded,omitempty"`
+	Copied     []reportCopy     `json:"copied,omitempty"`
+	Replaced   []reportReplace  `json:"replaced,omitempty"`
+	Written    []string         `json:"written,omitempty"`
+	Sizes      []reportSize     `json:"sizes,omitempty"`
+	Budgets    []reportBudget   `json:"budgets,omitempty"`
+}
+
+type reportMessage struct {
+	Text   string `json:"text"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+type reportFile struct {
+	Path string `json:"path"`
+	Size int    `json:"size"`
+}
+
+type reportSize struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	Gzip int64  `json:"gzip"`
+	// The deltas are missing if the previous build didn't have the file.
+	SizeDelta *int64 `json:"sizeDelta,omitempty"`
+	GzipDelta *int64 `json:"gzipDelta,omitempty"`
+}
+
+type reportBudget struct {
+	Kind    gowebbuild.BudgetKind `json:"kind"`
+	Path    string                `json:"path,omitempty"`
+	Size    int64                 `json:"size"`
+	Gzip    int64                 `json:"gzip"`
+	MaxSize int64                 `json:"maxSize,omitempty"`
+	MaxGzip int64                 `json:"maxGzip,omitempty"`
+	Over    bool                  `json:"over"`
+}
+
+type reportCopy struct {
+	Src  string `json:"src"`
+	Dest string `json:"dest"`
+}
+
+type reportReplace struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+func newBuildReport(dir string) *buildReport {
+	return &buildReport{start: time.Now(), setups: map[string]*setupReport{}, dir: dir, sizes: loadSizeState(dir)}
+}
+
+func (m reportMessage) String() string {
+	if m.File == "" {
+		return m.Text
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", m.File, m.Line, m.Column, m.Text)
+}
+
+func esbuildMessages(msgs []api.Message) []reportMessage {
+	list := []reportMessage{}
+	for _, msg := range msgs {
+		m := reportMessage{Text: msg.Text}
+		if msg.Location != nil {
+			m.File = msg.Location.File
+			m.Line = msg.Location.Line
+			m.Column = msg.Location.Column
+		}
+		list = append(list, m)
+	}
+
+	return list
+}
+
+// onEvent records ev. It's safe to use from pipelines that run concurrently.
+func (r *buildReport) onEvent(ev gowebbuild.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	setup, ok := r.setups[ev.Setup]
+	if !ok {
+		setup = &setupReport{Name: ev.Setup, Stages: []*stageReport{}}
+		r.setups[ev.Setup] = setup
+	}
+
+	if ev.Type == gowebbuild.EventStageStarted {
+		setup.Stages = append(setup.Stages, &stageReport{Stage: ev.Stage, Status: "running"})
+		return
+	}
+
+	stage := setup.stage(ev.Stage)
+
+	switch ev.Type {
+	case gowebbuild.EventStageFinished:
+		stage.Status = "ok"
+		stage.DurationMs = ev.Duration.Milliseconds()
+
+		if ev.Err != nil {
+			stage.Status = "failed"
+			stage.Error = stageErrText(ev.Err)
+		}
+
+		var buildErr *gowebbuild.BuildError
+		if errors.As(ev.Err, &buildErr) {
+			stage.Errors = esbuildMessages(buildErr.Errors)
+		}
+
+		if ev.BuildResult != nil {
+			stage.Warnings = append(stage.Warnings, esbuildMessages(ev.BuildResult.Warnings)...)
+
+			for _, f := range ev.BuildResult.OutputFiles {
+				stage.Outputs = append(stage.Outputs, reportFile{Path: f.Path, Size: len(f.Contents)})
+			}
+		}
+
+	case gowebbuild.EventWarning:
+		stage.Warnings = append(stage.Warnings, reportMessage{Text: ev.Message})
+	case gowebbuild.EventFilePurged:
+		stage.Purged = append(stage.Purged, ev.Path)
+	case gowebbuild.EventFileDownloaded:
+		stage.Downloaded = append(stage.Downloaded, reportCopy{Src: ev.Src, Dest: ev.Dest})
+	case gowebbuild.EventFileCopied:
+		stage.Copied = append(stage.Copied, reportCopy{Src: ev.Src, Dest: ev.Dest})
+	case gowebbuild.EventFileReplaced:
+		stage.Replaced = append(stage.Replaced, reportReplace{Path: ev.Path, Count: ev.Count})
+	case gowebbuild.EventFileWritten:
+		stage.Written = append(stage.Written, ev.Path)
+	case gowebbuild.EventSizes:
+		r.recordSizes(ev.Setup, stage, ev)
+	}
+}
+
+// recordSizes adds the sizes and budgets of the budgets stage to the report, with the deltas to the previous build.
+// The new sizes replace the previous ones in r.sizes.
+func (r *buildReport) recordSizes(setup string, stage *stageReport, ev gowebbuild.Event) {
+	prev := r.sizes[setup]
+	next := map[string]fileSizeState{}
+
+	for _, f := range ev.Sizes {
+		size := reportSize{Path: relPaths(r.dir, f.Path), Size: f.Size, Gzip: f.Gzip}
+
+		// Hashed files are compared by their logical name, their path changes with their content.
+		key := f.LogicalPath
+		if key == "" {
+			key = f.Path
+		}
+		key = relPaths(r.dir, key)
+		if p, ok := prev[key]; ok {
+			sizeDelta, gzipDelta := f.Size-p.Size, f.Gzip-p.Gzip
+			size.SizeDelta, size.GzipDelta = &sizeDelta, &gzipDelta
+		}
+
+		stage.Sizes = append(stage.Sizes, size)
+		next[key] = fileSizeState{Size: f.Size, Gzip: f.Gzip}
+	}
+
+	for _, b := range ev.Budgets {
+		path := b.Path
+		if path != "" {
+			path = relPaths(r.dir, path)
+		}
+
+		stage.Budgets = append(stage.Budgets, reportBudget{
+			Kind:    b.Kind,
+			Path:    path,
+			Size:    b.Size,
+			Gzip:    b.Gzip,
+			MaxSize: b.MaxSize,
+			MaxGzip: b.MaxGzip,
+			Over:    b.Over(),
+		})
+	}
+
+	r.sizes[setup] = next
+}
+
+// saveSizes writes the sizes of this build to the state file, if the budgets of a setup were checked.
+func (r *buildReport) saveSizes() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, setup := range r.setups {
+		for _, stage := range setup.Stages {
+			if stage.Stage == gowebbuild.StageBudgets && len(stage.Sizes) > 0 {
+				return r.sizes.save(r.dir)
+			}
+		}
+	}
+
+	return nil
+}
+
+// stage returns the report of the last run of the stage.
+func (s *setupReport) stage(stage gowebbuild.Stage) *stageReport {
+	for i := len(s.Stages) - 1; i >= 0; i-- {
+		if s.Stages[i].Stage == stage {
+			return s.Stages[i]
+		}
+	}
+
+	st := &stageReport{Stage: stage, Status: "ok"}
+	s.Stages = append(s.Stages, st)
+	return st
+}
+
+// stageErrText returns the error of a stage without the setup and stage, which the report already lists.
+func stageErrText(err error) string {
+	var stageErr *gowebbuild.StageError
+	if errors.As(err, &stageErr) {
+		err = stageErr.Err
+	}
+
+	var buildErr *gowebbuild.BuildError
+	if errors.As(err, &buildErr) {
+		return fmt.Sprintf("esbuild failed with %d error(s)", len(buildErr.Errors))
+	}
+
+	// The exceeded budgets are listed in the budgets table.
+	var budgetErr *gowebbuild.BudgetError
+	if errors.As(err, &budgetErr) {
+		return fmt.Sprintf("%d budget(s) exceeded", len(budgetErr.Over))
+	}
+
+	return err.Error()
+}
+
+// setupReports returns the reports of the setups in the order of results, with their outcome.
+func (r *buildReport) setupReports(results []gowebbuild.Result) []*setupReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := []*setupReport{}
+	for _, res := range results {
+		setup, ok := r.setups[res.Setup]
+		if !ok {
+			setup = &setupReport{Name: res.Setup, Stages: []*stageReport{}}
+		}
+
+		setup.DurationMs = res.Duration.Milliseconds()
+
+		switch {
+		case res.Skipped:
+			setup.Status = "skipped"
+		case res.Err != nil:
+			setup.Status = "failed"
+			setup.Error = res.Err.Error()
+		default:
+			setup.Status = "ok"
+		}
+
+		list = append(list, setup)
+	}
+
+	return list
+}
+
+// print lists the outcome and time of every setup, followed by the failures and warnings.
+func (r *buildReport) print(w io.Writer, results []gowebbuild.Result) {
+	setups := r.setupReports(results)
+
+	width := 0
+	for _, setup := range setups {
+		width = max(width, len(setup.Name))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Build summary:")
+	for _, setup := range setups {
+		duration := (time.Duration(setup.DurationMs) * time.Millisecond).String()
+
+		switch setup.Status {
+		case "skipped":
+			fmt.Fprintf(w, "  %-*s  skipped\n", width, setup.Name)
+		case "failed":
+			fmt.Fprintf(w, "  %-*s  failed after %s\n", width, setup.Name, duration)
+		default:
+			fmt.Fprintf(w, "  %-*s  ok in %s\n", width, setup.Name, duration)
+		}
+	}
+
+	for _, setup := range setups {
+		for _, stage := range setup.Stages {
+			if len(stage.Sizes) > 0 || len(stage.Budgets) > 0 {
+				printStageSizes(w, setup.Name, stage)
+			}
+		}
+	}
+
+	for _, setup := range setups {
+		if setup.Status != "failed" {
+			continue
+		}
+
+		if !slices.ContainsFunc(setup.Stages, func(s *stageReport) bool { return s.Status == "failed" }) {
+			fmt.Fprintf(w, "\nFailed: %s\n  %s\n", setup.Name, setup.Error)
+		}
+
+		for _, stage := range setup.Stages {
+			if stage.Status != "failed" {
+				continue
+			}
+
+			fmt.Fprintf(w, "\nFailed: %s (%s)\n", setup.Name, stage.Stage)
+			if len(stage.Errors) == 0 {
+				fmt.Fprintf(w, "  %s\n", stage.Error)
+			}
+
+			for _, msg := range stage.Errors {
+				fmt.Fprintf(w, "  %s\n", msg)
+			}
+		}
+	}
+
+	for _, setup := range setups {
+		header := false
+
+		for _, stage := range setup.Stages {
+			for _, msg := range stage.Warnings {
+				if !header {
+					fmt.Fprintf(w, "\nWarnings: %s\n", setup.Name)
+					header = true
+				}
+
+				fmt.Fprintf(w, "  %s: %s\n", stage.Stage, msg)
+			}
+		}
+	}
+}
+
+// printStageSizes prints the sizes of the output files with the change since the previous build and the checked budgets.
+func printStageSizes(w io.Writer, setup string, stage *stageReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "\nSizes: %s\n", setup)
+	for _, f := range stage.Sizes {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\tgzip %s\t%s\n", f.Path, gowebbuild.FormatSize(f.Size), sizeDelta(f.SizeDelta), gowebbuild.FormatSize(f.Gzip), sizeDelta(f.GzipDelta))
+	}
+	tw.Flush()
+
+	if len(stage.Budgets) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\nBudgets: %s\n", setup)
+	fmt.Fprintf(tw, "  budget\tsize\tlimit\tgzip\tlimit\tstatus\n")
+	for _, b := range stage.Budgets {
+		name := string(b.Kind)
+		if b.Path != "" {
+			name += " " + b.Path
+		}
+
+		status := "under"
+		if b.Over {
+			status = "OVER"
+		}
+
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\n", name, gowebbuild.FormatSize(b.Size), gowebbuild.FormatLimit(b.MaxSize), gowebbuild.FormatSize(b.Gzip), gowebbuild.FormatLimit(b.MaxGzip), status)
+	}
+	tw.Flush()
+}
+
+func sizeDelta(delta *int64) string {
+	switch {
+	case delta == nil:
+		return "(new)"
+	case *delta > 0:
+		return "(+" + gowebbuild.FormatSize(*delta) + ")"
+	case *delta < 0:
+		return "(-" + gowebbuild.FormatSize(-*delta) + ")"
+	default:
+		return "(±0)"
+	}
+}
+
+// writeJSON writes the report to path, or to stdout if path is empty. err is the error the build failed with.
+func (r *buildReport) writeJSON(path string, results []gowebbuild.Result, err error) error {
+	report := jsonReport{
+		Status:     "ok",
+		DurationMs: time.Since(r.start).Milliseconds(),
+		Setups:     r.setupReports(results),
+	}
+
+	if err != nil {
+		report.Status = "failed"
+		report.ExitCode = 1
+		report.Error = err.Error()
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
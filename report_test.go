@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
+)
+
+func TestRecordSizes(t *testing.T) {
+	dir := filepath.FromSlash("/project")
+	out := func(name string) string { return filepath.Join(dir, "dist", name) }
+
+	tests := []struct {
+		name        string
+		prev, sizes []gowebbuild.FileSize
+		// want lists the files with their size delta, "new" if there is none.
+		want []string
+	}{
+		{
+			name:  "unhashed file",
+			prev:  []gowebbuild.FileSize{{Path: out("index.js"), Size: 100}},
+			sizes: []gowebbuild.FileSize{{Path: out("index.js"), Size: 150}},
+			want:  []string{"dist/index.js +50"},
+		},
+		{
+			name:  "hashed file",
+			prev:  []gowebbuild.FileSize{{Path: out("index-AAAAAAAA.js"), LogicalPath: out("index.js"), Size: 100}},
+			sizes: []gowebbuild.FileSize{{Path: out("index-BBBBBBBB.js"), LogicalPath: out("index.js"), Size: 80}},
+			want:  []string{"dist/index-BBBBBBBB.js -20"},
+		},
+		{
+			name:  "new file",
+			prev:  []gowebbuild.FileSize{{Path: out("index-AAAAAAAA.js"), LogicalPath: out("index.js"), Size: 100}},
+			sizes: []gowebbuild.FileSize{{Path: out("admin-CCCCCCCC.js"), LogicalPath: out("admin.js"), Size: 100}},
+			want:  []string{"dist/admin-CCCCCCCC.js new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &buildReport{dir: dir, sizes: sizeState{}}
+			r.recordSizes("app", &stageReport{}, gowebbuild.Event{Sizes: tt.prev})
+
+			stage := &stageReport{}
+			r.recordSizes("app", stage, gowebbuild.Event{Sizes: tt.sizes})
+
+			got := []string{}
+			for _, size := range stage.Sizes {
+				delta := "new"
+				if size.SizeDelta != nil {
+					delta = fmt.Sprintf("%+d", *size.SizeDelta)
+				}
+				got = append(got, filepath.ToSlash(size.Path)+" "+delta)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sizes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
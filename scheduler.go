@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// defaultDebounce is the quiet period of a setup that doesn't configure watch.debounce.
+const defaultDebounce = 100 * time.Millisecond
+
+// maxCancels is the number of builds in a row changes may cancel, the next build finishes so a steady stream of changes
+// (like a code generator writing files one by one) still produces output.
+const maxCancels = 3
+
+// scheduler runs the builds of a setup in watch mode. Changes are collected until none arrived for the quiet period,
+// then they are built together. Only one build runs at a time: changes that arrive during a build and affect it cancel it,
+// and all changes since the last finished build are built by exactly one follow-up build.
+type scheduler struct {
+	quiet time.Duration
+	// build runs a build with the changed paths, or with nil for a full build. It has to stop when ctx is done.
+	build func(ctx context.Context, changes []string)
+	// affects reports whether changed paths make the running build outdated. It's called while the build runs.
+	affects func(changes []string) bool
+	changes chan []string
+	stopped chan struct{}
+}
+
+func newScheduler(quiet time.Duration, build func(ctx context.Context, changes []string), affects func(changes []string) bool) *scheduler {
+	if quiet <= 0 {
+		quiet = defaultDebounce
+	}
+
+	return &scheduler{quiet: quiet, build: build, affects: affects, changes: make(chan []string, 64), stopped: make(chan struct{})}
+}
+
+// changed schedules a build for the changed paths. Without paths the next build is a full build.
+// Changes after the scheduler stopped are ignored.
+func (s *scheduler) changed(paths ...string) {
+	select {
+	case s.changes <- paths:
+	case <-s.stopped:
+	}
+}
+
+// run executes the scheduled builds until ctx is done. A running build is canceled and waited for before run returns.
+func (s *scheduler) run(ctx context.Context) {
+	defer close(s.stopped)
+
+	pending := map[string]bool{}
+	full := false
+	// due is set when the quiet period ended during a build, the follow-up build starts as soon as that build is done.
+	due := false
+
+	quiet := time.NewTimer(s.quiet)
+	quiet.Stop()
+
+	var building []string
+	var buildFull bool
+	var cancelBuild context.CancelFunc
+	var buildCtx context.Context
+	var done chan struct{}
+	// cancels counts the builds canceled in a row.
+	cancels := 0
+
+	start := func() {
+		due = false
+		buildFull = full
+		building = make([]string, 0, len(pending))
+		for path := range pending {
+			building = append(building, path)
+		}
+		sort.Strings(building)
+
+		pending = map[string]bool{}
+		full = false
+
+		changes := building
+		if buildFull {
+			changes = nil
+		}
+
+		buildCtx, cancelBuild = context.WithCancel(ctx)
+		done = make(chan struct{})
+		go func(ctx context.Context, done chan struct{}) {
+			defer close(done)
+			s.build(ctx, changes)
+		}(buildCtx, done)
+	}
+
+	for {
+		select {
+		case paths := <-s.changes:
+			if len(paths) == 0 {
+				full = true
+			}
+			for _, path := range paths {
+				pending[path] = true
+			}
+
+			// A full build is requested when the setup has to be built from scratch, the running build is outdated then.
+			if done != nil && buildCtx.Err() == nil && (len(paths) == 0 || s.affects(paths)) {
+				if cancels < maxCancels {
+					fmt.Println("Files changed during the build, canceling it")
+					cancels++
+					cancelBuild()
+				} else if cancels == maxCancels {
+					fmt.Printf("Letting the build finish, it was canceled %d times in a row\n", cancels)
+					cancels++
+				}
+			}
+
+			quiet.Reset(s.quiet)
+		case <-quiet.C:
+			if done != nil {
+				due = true
+				continue
+			}
+			start()
+		case <-done:
+			canceled := buildCtx.Err() != nil
+			cancelBuild()
+			done = nil
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if !canceled {
+				cancels = 0
+			}
+
+			// The changes of a canceled build still need to be built.
+			if canceled {
+				full = full || buildFull
+				for _, path := range building {
+					pending[path] = true
+				}
+			}
+
+			if due {
+				start()
+			}
+		case <-ctx.Done():
+			quiet.Stop()
+			if done != nil {
+				cancelBuild()
+				<-done
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	const quiet = 10 * time.Millisecond
+	// Builds that aren't canceled take this long.
+	const buildTime = 100 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		changes [][]string
+		// during lists the changes made while the first, second, ... build runs. An empty list requests a full build.
+		during  [][]string
+		affects bool
+		// want lists the builds with their changes ("full" for a full build) and whether they were canceled.
+		want []string
+	}{
+		{
+			name:    "changes are built together",
+			changes: [][]string{{"b"}, {"a"}, {"b"}},
+			want:    []string{"a,b"},
+		},
+		{
+			name:    "full build",
+			changes: [][]string{{}, {"a"}},
+			want:    []string{"full"},
+		},
+		{
+			name:    "affecting change cancels the build",
+			changes: [][]string{{"a"}},
+			during:  [][]string{{"b"}},
+			affects: true,
+			want:    []string{"a canceled", "a,b"},
+		},
+		{
+			name:    "other change lets the build finish",
+			changes: [][]string{{"a"}},
+			during:  [][]string{{"b"}},
+			want:    []string{"a", "b"},
+		},
+		{
+			name:    "full build request cancels the build",
+			changes: [][]string{{"a"}},
+			during:  [][]string{{}},
+			want:    []string{"a canceled", "full"},
+		},
+		{
+			name:    "build finishes after too many cancellations",
+			changes: [][]string{{"a"}},
+			during:  [][]string{{"b"}, {"c"}, {"d"}, {"e"}, {"f"}},
+			affects: true,
+			want:    []string{"a canceled", "a,b canceled", "a,b,c canceled", "a,b,c,d", "e canceled", "e,f"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var mu sync.Mutex
+			builds := []string{}
+			var s *scheduler
+
+			s = newScheduler(quiet, func(ctx context.Context, changes []string) {
+				mu.Lock()
+				n := len(builds)
+				builds = append(builds, "")
+				mu.Unlock()
+
+				if n < len(tt.during) {
+					s.changed(tt.during[n]...)
+				}
+
+				build := "full"
+				if changes != nil {
+					build = strings.Join(changes, ",")
+				}
+
+				select {
+				case <-ctx.Done():
+					build += " canceled"
+				case <-time.After(buildTime):
+				}
+
+				mu.Lock()
+				builds[n] = build
+				mu.Unlock()
+			}, func(changes []string) bool {
+				return tt.affects
+			})
+
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				s.run(ctx)
+			}()
+
+			for _, changes := range tt.changes {
+				s.changed(changes...)
+			}
+
+			// Wait for the expected builds and a bit longer to catch unexpected ones.
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				mu.Lock()
+				done := len(builds) >= len(tt.want) && builds[len(builds)-1] != ""
+				mu.Unlock()
+
+				if done || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(quiet)
+			}
+			time.Sleep(5 * quiet)
+
+			cancel()
+			<-stopped
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !slices.Equal(builds, tt.want) {
+				t.Errorf("builds = %q, want %q", builds, tt.want)
+			}
+		})
+	}
+}
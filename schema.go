@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
+	"github.com/urfave/cli/v2"
+)
+
+func configSchemaAction(ctx *cli.Context) error {
+	data, err := json.MarshalIndent(gowebbuild.Schema(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	out := ctx.String("o")
+	if out == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	err = os.WriteFile(out, append(data, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote JSON schema to %s\n", out)
+	return nil
+}
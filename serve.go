@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/kataras/iris/v12"
 )
 
-func Serve(root string, port uint) error {
+// Serve serves root on the given port until ctx is done.
+func Serve(ctx context.Context, root string, port uint) error {
 	app := iris.New()
+	app.UseRouter(precompressed(root))
 	app.HandleDir("/", iris.Dir(root), iris.DirOptions{
 		IndexName:  "/index.html",
 		Compress:   false,
@@ -18,5 +27,83 @@ func Serve(root string, port uint) error {
 		},
 	})
 
-	return app.Listen(fmt.Sprintf(":%d", port))
+	go func() {
+		<-ctx.Done()
+		app.Shutdown(context.Background())
+	}()
+
+	return app.Listen(fmt.Sprintf(":%d", port), iris.WithoutServerError(iris.ErrServerClosed))
+}
+
+// precompressedEncodings are the precompressed files written by the compress stage, the preferred one first.
+var precompressedEncodings = []struct {
+	name string
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// precompressed serves the .br or .gz file next to a requested file if the client accepts the encoding.
+// Compressed files that are older than the file itself (left over from an earlier production build) are ignored.
+func precompressed(root string) iris.Handler {
+	return func(ctx iris.Context) {
+		file := filepath.Join(root, filepath.FromSlash(path.Clean("/"+ctx.Path())))
+		// fsutils.IsDir creates missing folders, a request for a missing path must not.
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			file = filepath.Join(file, "index.html")
+		}
+
+		info, err := os.Stat(file)
+		contentType := mime.TypeByExtension(filepath.Ext(file))
+		if err != nil || info.IsDir() || contentType == "" {
+			ctx.Next()
+			return
+		}
+
+		accepted := acceptedEncodings(ctx.GetHeader("Accept-Encoding"))
+
+		for _, enc := range precompressedEncodings {
+			if !accepted[enc.name] {
+				continue
+			}
+
+			compressed, err := os.Stat(file + enc.ext)
+			if err != nil || compressed.ModTime().Before(info.ModTime()) {
+				continue
+			}
+
+			f, err := os.Open(file + enc.ext)
+			if err != nil {
+				continue
+			}
+			defer f.Close()
+
+			w := ctx.ResponseWriter()
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Encoding", enc.name)
+			w.Header().Add("Vary", "Accept-Encoding")
+			http.ServeContent(w, ctx.Request(), file, compressed.ModTime(), f)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// acceptedEncodings parses an Accept-Encoding header, like "gzip, deflate, br;q=0.9".
+func acceptedEncodings(header string) map[string]bool {
+	accepted := map[string]bool{}
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := strings.ReplaceAll(params, " ", "")
+		if name == "" || q == "q=0" || q == "q=0.0" || q == "q=0.00" || q == "q=0.000" {
+			continue
+		}
+
+		accepted[strings.ToLower(name)] = true
+	}
+
+	return accepted
 }
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/trading-peter/gowebbuild/fsutils"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// enumViews turns the esbuild enum types into their names, so the build options are readable.
+// The types with a config counterpart reuse its names, the others only exist in the build options.
+var enumViews = map[reflect.Type]func(v reflect.Value) any{
+	reflect.TypeOf(api.Format(0)):    func(v reflect.Value) any { return enumView(gowebbuild.Format(v.Interface().(api.Format))) },
+	reflect.TypeOf(api.Platform(0)):  func(v reflect.Value) any { return enumView(gowebbuild.Platform(v.Interface().(api.Platform))) },
+	reflect.TypeOf(api.SourceMap(0)): func(v reflect.Value) any { return enumView(gowebbuild.SourceMap(v.Interface().(api.SourceMap))) },
+	reflect.TypeOf(api.LogLevel(0)):  func(v reflect.Value) any { return enumView(gowebbuild.LogLevel(v.Interface().(api.LogLevel))) },
+	reflect.TypeOf(api.Target(0)):    func(v reflect.Value) any { return enumView(gowebbuild.Target{Target: v.Interface().(api.Target)}) },
+	reflect.TypeOf(api.Loader(0)):    func(v reflect.Value) any { return enumView(gowebbuild.Loader(v.Interface().(api.Loader))) },
+	reflect.TypeOf(api.LegalComments(0)): func(v reflect.Value) any {
+		return enumView(gowebbuild.LegalComments(v.Interface().(api.LegalComments)))
+	},
+	reflect.TypeOf(api.JSX(0)):      func(v reflect.Value) any { return enumView(gowebbuild.JSX(v.Interface().(api.JSX))) },
+	reflect.TypeOf(api.Charset(0)):  func(v reflect.Value) any { return enumView(gowebbuild.Charset(v.Interface().(api.Charset))) },
+	reflect.TypeOf(api.Packages(0)): func(v reflect.Value) any { return enumView(gowebbuild.Packages(v.Interface().(api.Packages))) },
+	reflect.TypeOf(api.Drop(0)):     func(v reflect.Value) any { return enumView(gowebbuild.Drop(v.Interface().(api.Drop))) },
+	reflect.TypeOf(api.Engine{}): func(v reflect.Value) any {
+		return enumView(gowebbuild.Target{Engines: []api.Engine{v.Interface().(api.Engine)}})
+	},
+	reflect.TypeOf(api.StderrColor(0)): func(v reflect.Value) any {
+		return []string{"ifTerminal", "never", "always"}[v.Uint()]
+	},
+	reflect.TypeOf(api.SourcesContent(0)): func(v reflect.Value) any {
+		return []string{"include", "exclude"}[v.Uint()]
+	},
+	reflect.TypeOf(api.TreeShaking(0)): func(v reflect.Value) any {
+		return []string{"default", "false", "true"}[v.Uint()]
+	},
+	reflect.TypeOf(api.MangleQuoted(0)): func(v reflect.Value) any {
+		return []string{"false", "true"}[v.Uint()]
+	},
+}
+
+func enumView(m yaml.Marshaler) any {
+	v, err := m.MarshalYAML()
+	if err != nil {
+		return err.Error()
+	}
+
+	return v
+}
+
+type shownSetup struct {
+	Setup        string `yaml:"setup" json:"setup"`
+	Config       any    `yaml:"config" json:"config"`
+	BuildOptions any    `yaml:"buildOptions" json:"buildOptions"`
+}
+
+// configShowAction prints the setups as gowebbuild uses them: with defaults, extends, the profile and variables applied
+// and all paths resolved. With --resolved it also prints the build options that are handed to esbuild.
+func configShowAction(ctx *cli.Context) error {
+	cfgPath := fsutils.ExpandPath(ctx.String("c"))
+
+	os.Chdir(filepath.Dir(cfgPath))
+	cfg, err := gowebbuild.LoadConfig(cfgPath, gowebbuild.WithProfile(ctx.String("profile")))
+	if err != nil {
+		return err
+	}
+	printCfgWarnings(cfg)
+
+	optsSetups, err := gowebbuild.SelectSetups(cfg.Setups, ctx.StringSlice("only"), ctx.StringSlice("skip"))
+	if err != nil {
+		return err
+	}
+
+	setups := []any{}
+	for i, opts := range optsSetups {
+		setupCfg := plainValue(reflect.ValueOf(opts), yamlKey)
+
+		if !ctx.Bool("resolved") {
+			setups = append(setups, setupCfg)
+			continue
+		}
+
+		buildOptions := gowebbuild.ESBuildOptions(opts)
+		// Plugins are added by the build itself and can't be printed.
+		buildOptions.Plugins = nil
+
+		setups = append(setups, shownSetup{
+			Setup:        gowebbuild.SetupName(opts, i),
+			Config:       setupCfg,
+			BuildOptions: plainValue(reflect.ValueOf(buildOptions), lowerCamelKey),
+		})
+	}
+
+	switch ctx.String("format") {
+	case "json":
+		data, err := json.MarshalIndent(setups, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+
+	case "yaml":
+		data, err := gowebbuild.EncodeYAML(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{yamlValue(setups)}})
+		if err != nil {
+			return err
+		}
+		fmt.Print(data)
+
+	default:
+		return fmt.Errorf("unknown format %q, expected yaml or json", ctx.String("format"))
+	}
+
+	return nil
+}
+
+// orderedMap keeps the field order of structs when printed as YAML (JSON output is sorted by key).
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.values)
+}
+
+func (m orderedMap) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range m.keys {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, yamlValue(m.values[key]))
+	}
+
+	return node, nil
+}
+
+func yamlValue(v any) *yaml.Node {
+	node := &yaml.Node{}
+	if err := node.Encode(v); err != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: err.Error()}
+	}
+
+	return node
+}
+
+func yamlKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+
+	return name
+}
+
+func lowerCamelKey(f reflect.StructField) string {
+	// Keep acronyms like JSX or ID lowercase as a whole.
+	i := 0
+	for i < len(f.Name) && f.Name[i] >= 'A' && f.Name[i] <= 'Z' {
+		i++
+	}
+	if i > 1 && i < len(f.Name) {
+		i--
+	}
+
+	return strings.ToLower(f.Name[:i]) + f.Name[i:]
+}
+
+// plainValue converts v into maps, lists and scalars for printing. Zero values are left out.
+func plainValue(v reflect.Value, key func(reflect.StructField) string) any {
+	if view, ok := enumViews[v.Type()]; ok {
+		return view(v)
+	}
+
+	if m, ok := v.Interface().(yaml.Marshaler); ok {
+		out, err := m.MarshalYAML()
+		if err != nil {
+			return err.Error()
+		}
+		return out
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return plainValue(v.Elem(), key)
+
+	case reflect.Struct:
+		m := orderedMap{values: map[string]any{}}
+
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() || f.Tag.Get("yaml") == "-" || isEmptyValue(v.Field(i)) {
+				continue
+			}
+
+			name := key(f)
+			m.keys = append(m.keys, name)
+			m.values[name] = plainValue(v.Field(i), key)
+		}
+
+		return m
+
+	case reflect.Slice:
+		list := []any{}
+		for i := 0; i < v.Len(); i++ {
+			list = append(list, plainValue(v.Index(i), key))
+		}
+		return list
+
+	case reflect.Map:
+		m := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = plainValue(iter.Value(), key)
+		}
+		return m
+
+	case reflect.Func, reflect.Chan:
+		return nil
+	}
+
+	return v.Interface()
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Func:
+		return true
+	}
+
+	return v.IsZero()
+}
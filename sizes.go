@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/trading-peter/gowebbuild/fsutils"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
+)
+
+// sizeStateFile keeps the output file sizes of the last production build of each setup, in the cache folder of the project
+// (see gowebbuild.CacheDir) so builds don't change the working tree.
+const sizeStateFile = "sizes.json"
+
+type fileSizeState struct {
+	Size int64 `json:"size"`
+	Gzip int64 `json:"gzip"`
+}
+
+// sizeState maps setup names to the sizes of their output files. Paths are relative to the folder of the config file,
+// hashed files are listed by their logical path (see gowebbuild.FileSize).
+type sizeState map[string]map[string]fileSizeState
+
+func loadSizeState(dir string) sizeState {
+	state := sizeState{}
+	path := filepath.Join(gowebbuild.CacheDir(dir), sizeStateFile)
+
+	if !fsutils.IsFile(path) {
+		return state
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state
+	}
+
+	// A broken state file only means there are no deltas this time.
+	json.Unmarshal(data, &state)
+	return state
+}
+
+func (s sizeState) save(dir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	cache := gowebbuild.CacheDir(dir)
+	if err := os.MkdirAll(cache, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(cache, sizeStateFile), append(data, '\n'), 0644)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/trading-peter/gowebbuild/fsutils"
+	"github.com/urfave/cli/v2"
+)
+
+func configValidateAction(ctx *cli.Context) error {
+	cfgPath := fsutils.ExpandPath(ctx.String("c"))
+
+	os.Chdir(filepath.Dir(cfgPath))
+	opts, err := loadCfg(cfgPath, ctx.String("profile"), true)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is valid (%d setup(s))\n", cfgPath, len(opts))
+	return nil
+}
@@ -1,16 +1,35 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/jaschaephraim/lrserver"
-	"github.com/radovskyb/watcher"
+	"github.com/trading-peter/gowebbuild/internal/fswatch"
+	"github.com/trading-peter/gowebbuild/pkg/gowebbuild"
 	"github.com/urfave/cli/v2"
 )
 
+// runningSetup holds the goroutines (file watcher, serve instance and link watcher) started for one setup in watch mode.
+type runningSetup struct {
+	opts     gowebbuild.Options
+	pipeline *gowebbuild.Pipeline
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// stop shuts down all goroutines of the setup, waits until they are gone (including a running build) and disposes the esbuild context.
+func (r *runningSetup) stop() {
+	r.cancel()
+	r.wg.Wait()
+	r.pipeline.Close()
+}
+
 func watchAction(ctx *cli.Context) error {
 	cfgPath, err := filepath.Abs(ctx.String("c"))
 	if err != nil {
@@ -20,113 +39,374 @@ func watchAction(ctx *cli.Context) error {
 	lrport := ctx.Uint("lr-port")
 	fmt.Printf("Live reload is running on port %d\n", lrport)
 
-	os.Chdir(filepath.Dir(cfgPath))
-	optsSetups := readCfg(cfgPath)
+	loadSetups := func() ([]gowebbuild.Options, []string, error) {
+		optsSetups, cfgFiles, err := loadCfgFiles(cfgPath, ctx.String("profile"), true)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	pipeline := func(opts options) {
-		purge(opts)
-		cp(opts)
-		build(opts)
-		injectLR(lrport, opts)
-		replace(opts)
+		optsSetups, err = gowebbuild.SelectSetups(optsSetups, ctx.StringSlice("only"), ctx.StringSlice("skip"))
+		return optsSetups, cfgFiles, err
 	}
 
-	for i := range optsSetups {
-		opts := optsSetups[i]
+	os.Chdir(filepath.Dir(cfgPath))
+	optsSetups, cfgFiles, err := loadSetups()
+	if err != nil {
+		return err
+	}
 
-		go func(opts options) {
-			w := watcher.New()
-			w.SetMaxEvents(1)
-			w.FilterOps(watcher.Write, watcher.Rename, watcher.Move, watcher.Create, watcher.Remove)
+	// running is replaced by the config watcher and read on shutdown.
+	var runningMu sync.Mutex
+	running := map[string]*runningSetup{}
+	for i, opts := range optsSetups {
+		r, err := watchSetup(ctx.Context, opts, gowebbuild.SetupName(opts, i), lrport)
+		if err != nil {
+			for _, r := range running {
+				r.stop()
+			}
+			return err
+		}
 
-			if len(opts.Watch.Exclude) > 0 {
-				w.Ignore(opts.Watch.Exclude...)
+		running[gowebbuild.SetupName(opts, i)] = r
+	}
+
+	go func() {
+		fmt.Println("Starting live reload server.")
+		lr := lrserver.New(lrserver.DefaultName, uint16(lrport))
+
+		go func() {
+			for {
+				<-triggerReload
+				lr.Reload("")
 			}
+		}()
+
+		lr.SetStatusLog(nil)
+		err := lr.ListenAndServe()
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	go watchCfg(ctx.Context, cfgFiles, func(w fswatch.Watcher) {
+		newSetups, newFiles, err := loadSetups()
+		if err != nil {
+			fmt.Printf("Config is invalid, keeping the previous one:\n%v\n", err)
+			return
+		}
+
+		runningMu.Lock()
+		defer runningMu.Unlock()
+
+		changed := false
+		updated := map[string]*runningSetup{}
+		for i, opts := range newSetups {
+			name := gowebbuild.SetupName(opts, i)
 
-			if opts.ESBuild.Outdir != "" {
-				w.Ignore(opts.ESBuild.Outdir)
+			if r, ok := running[name]; ok && reflect.DeepEqual(r.opts, opts) {
+				updated[name] = r
+				delete(running, name)
+				continue
 			}
 
-			for _, p := range opts.Watch.Paths {
-				w.Ignore(filepath.Join(p, ".git"))
+			changed = true
+			if r, ok := running[name]; ok {
+				fmt.Printf("Setup %s changed, restarting it\n", name)
+				r.stop()
+				delete(running, name)
 
-				if err := w.AddRecursive(p); err != nil {
-					fmt.Println(err.Error())
-					os.Exit(1)
+				if !reflect.DeepEqual(r.opts.NpmProxy, opts.NpmProxy) {
+					fmt.Printf("Setup %s changed its npm proxy settings, restart watch to apply them\n", name)
 				}
+			} else {
+				fmt.Printf("Setup %s added, starting it\n", name)
 			}
 
-			go func() {
-				for {
-					select {
-					case event := <-w.Event:
-						fmt.Printf("File %s changed\n", event.Path)
-						pipeline(opts)
-					case err := <-w.Error:
-						fmt.Println(err.Error())
-					case <-w.Closed:
-						return
-					}
-				}
-			}()
+			r, err := watchSetup(ctx.Context, opts, name, lrport)
+			if err != nil {
+				// It's started again by the next config change.
+				fmt.Printf("Setup %s can't be watched: %v\n", name, err)
+				continue
+			}
 
-			fmt.Printf("Watching %d elements in %s\n", len(w.WatchedFiles()), opts.Watch.Paths)
+			updated[name] = r
+		}
 
-			pipeline(opts)
+		for name, r := range running {
+			changed = true
+			fmt.Printf("Setup %s removed, stopping it\n", name)
+			r.stop()
+		}
 
-			if err := w.Start(time.Millisecond * 100); err != nil {
-				fmt.Println(err.Error())
+		if !changed {
+			fmt.Println("No setup changed")
+		}
+
+		running = updated
+		updateCfgWatcher(w, cfgFiles, newFiles)
+		cfgFiles = newFiles
+	})
+
+	runProxy(ctx.Context, filepath.Dir(cfgPath), optsSetups)
+	<-ctx.Done()
+
+	runningMu.Lock()
+	for _, r := range running {
+		r.stop()
+	}
+	runningMu.Unlock()
+
+	fmt.Println("Stopped watching.")
+
+	return nil
+}
+
+// watchSetup builds the setup once and then rebuilds it on changes until ctx is done or the returned runningSetup is stopped.
+// Rebuilds are incremental (they reuse the esbuild context of the setup) and debounced by a scheduler.
+// It fails if the watch paths can't be watched, nothing is started then.
+func watchSetup(ctx context.Context, opts gowebbuild.Options, name string, lrport uint) (*runningSetup, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// stagesRan is set by the stages of a build, builds that only find out that no stage is affected don't reload the page.
+	stagesRan := false
+	p := gowebbuild.New(opts,
+		gowebbuild.WithName(name),
+		gowebbuild.WithStages(gowebbuild.WatchStages()...),
+		gowebbuild.WithIncremental(),
+		gowebbuild.WithLiveReloadPort(lrport),
+		gowebbuild.WithEventHandler(func(ev gowebbuild.Event) {
+			printEvent(ev)
+
+			if ev.Type == gowebbuild.EventStageFinished {
+				stagesRan = true
 			}
-		}(opts)
+		}),
+	)
 
-		if opts.Serve.Path != "" {
-			go func() {
-				port := 8080
-				if opts.Serve.Port != 0 {
-					port = opts.Serve.Port
-				}
+	r := &runningSetup{opts: opts, pipeline: p, cancel: cancel}
 
-				err := Serve(opts.Serve.Path, uint(port))
+	exclude := append([]string{}, opts.Watch.Exclude...)
+	if opts.ESBuild.Outdir != "" {
+		exclude = append(exclude, opts.ESBuild.Outdir)
+	}
 
-				if err != nil {
-					fmt.Printf("%+v\n", err.Error())
-					os.Exit(1)
-				}
-			}()
+	for _, path := range opts.Watch.Paths {
+		exclude = append(exclude, filepath.Join(path, ".git"))
+	}
+
+	w, err := fswatch.New(fswatch.Options{
+		Backend: fswatch.Backend(opts.Watch.Backend),
+		Exclude: exclude,
+		Log:     func(msg string) { fmt.Println(msg) },
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	for _, path := range opts.Watch.Paths {
+		if err := w.Add(path); err != nil {
+			w.Close()
+			cancel()
+			return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+	}
+
+	if len(opts.Watch.Paths) > 0 {
+		fmt.Printf("Watching %d folder(s) in %s\n", w.Len(), opts.Watch.Paths)
+	}
+
+	// autoPaths are the paths watched because of watch.auto, they follow the paths the pipeline reports after every build.
+	autoPaths := map[string]bool{}
+	updateAutoPaths := func() {
+		paths := map[string]bool{}
+		for _, path := range p.WatchPaths() {
+			paths[path] = true
 		}
 
-		if opts.Link.From != "" {
-			reqBuildCh := link(opts.Link.From, opts.Link.To)
+		added, removed := 0, 0
+		for path := range autoPaths {
+			if !paths[path] {
+				w.Remove(path)
+				delete(autoPaths, path)
+				removed++
+			}
+		}
+
+		for path := range paths {
+			if autoPaths[path] {
+				continue
+			}
 
-			go func() {
-				for range reqBuildCh {
-					pipeline(opts)
+			if err := w.Add(path); err != nil {
+				if !os.IsNotExist(err) && ctx.Err() == nil {
+					fmt.Printf("Failed to watch %s: %v\n", path, err)
 				}
-			}()
+				continue
+			}
+
+			autoPaths[path] = true
+			added++
+		}
+
+		if added > 0 || removed > 0 {
+			fmt.Printf("Watching %d path(s) used by the build (%d added, %d removed)\n", len(autoPaths), added, removed)
 		}
 	}
 
+	sched := newScheduler(time.Duration(opts.Watch.Debounce), func(ctx context.Context, changes []string) {
+		switch {
+		case len(changes) == 1:
+			fmt.Printf("File %s changed\n", changes[0])
+		case len(changes) > 1:
+			fmt.Printf("%d files changed\n", len(changes))
+		}
+
+		stagesRan = false
+		err := p.RunChanges(ctx, changes)
+
+		if opts.Watch.Auto && ctx.Err() == nil {
+			updateAutoPaths()
+		}
+
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Println(err)
+			}
+			return
+		}
+
+		if stagesRan {
+			triggerReload <- struct{}{}
+		}
+	}, p.Affects)
+
+	r.wg.Add(1)
 	go func() {
-		fmt.Println("Starting live reload server.")
-		lr := lrserver.New(lrserver.DefaultName, uint16(lrport))
+		defer r.wg.Done()
+		sched.run(ctx)
+	}()
 
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer w.Close()
+
+		sched.changed()
+
+		for {
+			select {
+			case event := <-w.Events():
+				sched.changed(event.Path)
+			case err := <-w.Errors():
+				fmt.Println(err.Error())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if opts.Serve.Path != "" {
+		r.wg.Add(1)
 		go func() {
-			for {
-				<-triggerReload
-				lr.Reload("")
+			defer r.wg.Done()
+
+			port := 8080
+			if opts.Serve.Port != 0 {
+				port = opts.Serve.Port
+			}
+
+			err := Serve(ctx, opts.Serve.Path, uint(port))
+
+			if err != nil {
+				fmt.Printf("%+v\n", err.Error())
 			}
 		}()
+	}
 
-		lr.SetStatusLog(nil)
-		err := lr.ListenAndServe()
+	if opts.Link.From != "" {
+		reqBuildCh, err := p.Link(ctx)
 		if err != nil {
-			panic(err)
+			fmt.Printf("%+v\n", err)
+			return r, nil
 		}
-	}()
 
-	runProxy(ctx.Context, filepath.Dir(cfgPath), optsSetups)
-	<-ctx.Done()
-	fmt.Println("Stopped watching.")
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
 
-	return nil
+			for {
+				select {
+				case <-reqBuildCh:
+					sched.changed()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	return r, nil
+}
+
+// watchCfg calls onChange whenever one of the config files (including extended and included files) changes.
+func watchCfg(ctx context.Context, files []string, onChange func(w fswatch.Watcher)) {
+	w, err := fswatch.New(fswatch.Options{Log: func(msg string) { fmt.Println(msg) }})
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer w.Close()
+
+	updateCfgWatcher(w, nil, files)
+
+	for {
+		select {
+		case event := <-w.Events():
+			if event.Op == fswatch.Remove {
+				continue
+			}
+
+			fmt.Printf("Config file %s changed, reloading\n", event.Path)
+			drainEvents(w)
+			onChange(w)
+		case err := <-w.Errors():
+			fmt.Println(err.Error())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// updateCfgWatcher makes w watch the config files of the reloaded config, which may extend or include other files now.
+func updateCfgWatcher(w fswatch.Watcher, oldFiles, newFiles []string) {
+	keep := map[string]bool{}
+	for _, f := range newFiles {
+		keep[f] = true
+	}
+
+	for _, f := range oldFiles {
+		if !keep[f] {
+			w.Remove(f)
+		}
+	}
+
+	for _, f := range newFiles {
+		if err := w.Add(f); err != nil {
+			fmt.Printf("Failed to watch config file %s: %v\n", f, err)
+		}
+	}
+}
+
+// drainEvents discards the events that are already queued, after a short pause to let a burst of changes arrive.
+func drainEvents(w fswatch.Watcher) {
+	time.Sleep(50 * time.Millisecond)
+
+	for {
+		select {
+		case <-w.Events():
+		default:
+			return
+		}
+	}
 }